@@ -2,39 +2,82 @@ package aliases
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/iancoleman/strcase"
 
 	"github.com/growthbook/gb-find-code-refs/internal/helpers"
 	"github.com/growthbook/gb-find-code-refs/internal/log"
-	"github.com/growthbook/gb-find-code-refs/internal/validation"
 	"github.com/growthbook/gb-find-code-refs/options"
 )
 
-// GenerateAliases returns a map of flag keys to aliases based on config.
-func GenerateAliases(flags []string, aliases []options.Alias, dir string) (map[string][]string, error) {
-	allFileContents, err := processFileContent(aliases, dir)
+// GenerateAliases returns a map of flag keys to aliases based on config. Alias commands are cancelled with ctx, and
+// run in dir. The files read by filepattern, structured and constants aliases are read from files, or from dir if
+// files is nil.
+func GenerateAliases(ctx context.Context, flags []string, aliases []options.Alias, dir string, files fs.FS) (map[string][]string, error) {
+	if files == nil {
+		files = os.DirFS(workingDir(dir))
+	}
+	allFileContents, err := processFileContent(aliases, dir, files)
 	if err != nil {
 		return nil, err
 	}
 
+	// batch commands run once for all flags
+	precomputedAliasesByIndex := map[int]map[string][]string{}
+	for i, a := range aliases {
+		if a.Type.Canonical() != options.Command || !a.Batch {
+			continue
+		}
+		if a.Name == "" {
+			a.Name = strconv.Itoa(i)
+		}
+		precomputedAliasesByIndex[i], err = GenerateAliasesFromCommandBatch(ctx, a, flags, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// structured data and source files are parsed once, and their aliases indexed by the flag key they were matched against
+	for i, a := range aliases {
+		if a.Name == "" {
+			a.Name = strconv.Itoa(i)
+		}
+		switch a.Type.Canonical() {
+		case options.Structured:
+			precomputedAliasesByIndex[i], err = GenerateAliasesFromStructuredData(a, dir, files, allFileContents)
+		case options.Constants:
+			precomputedAliasesByIndex[i], err = GenerateAliasesFromConstants(a, dir, files, allFileContents)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	ret := make(map[string][]string, len(flags))
 	for _, flag := range flags {
 		for i, a := range aliases {
+			if batchAliases, ok := precomputedAliasesByIndex[i]; ok {
+				ret[flag] = append(ret[flag], batchAliases[flag]...)
+				continue
+			}
 			if a.Name == "" {
 				a.Name = strconv.Itoa(i)
 			}
-			flagAliases, err := generateAlias(a, flag, dir, allFileContents)
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			flagAliases, err := generateAlias(ctx, a, flag, dir, files, allFileContents)
 			if err != nil {
 				return nil, err
 			}
@@ -45,14 +88,20 @@ func GenerateAliases(flags []string, aliases []options.Alias, dir string) (map[s
 	return ret, nil
 }
 
-func generateAlias(a options.Alias, flag, dir string, allFileContents FileContentsMap) (ret []string, err error) {
+func generateAlias(ctx context.Context, a options.Alias, flag, dir string, files fs.FS, allFileContents FileContentsMap) (ret []string, err error) {
 	switch a.Type.Canonical() {
 	case options.Literal:
 		ret = a.Flags[flag]
 	case options.FilePattern:
-		ret, err = GenerateAliasesFromFilePattern(a, flag, dir, allFileContents)
+		ret, err = GenerateAliasesFromFilePattern(a, flag, dir, files, allFileContents)
 	case options.Command:
-		ret, err = GenerateAliasesFromCommand(a, flag, dir)
+		ret, err = GenerateAliasesFromCommand(ctx, a, flag, dir)
+	case options.Regex:
+		// regex aliases are matched against source lines while searching, see GenerateRegexAliases
+	case options.Transform:
+		var alias string
+		alias, err = GenerateTransformAlias(a, flag)
+		ret = []string{alias}
 	default:
 		var alias string
 		alias, err = GenerateNamingConventionAlias(a, flag)
@@ -62,8 +111,75 @@ func generateAlias(a options.Alias, flag, dir string, allFileContents FileConten
 	return ret, err
 }
 
+// GenerateRegexAliases returns a map of flag keys to the compiled patterns of regex aliases, with the FLAG_KEY
+// placeholders replaced by the quoted flag key, transformed as the placeholder specifies, e.g.
+// {FLAG_KEY:upper_snake}
+func GenerateRegexAliases(flags []string, aliases []options.Alias) (map[string][]*regexp.Regexp, error) {
+	ret := map[string][]*regexp.Regexp{}
+	for i, a := range aliases {
+		if a.Type.Canonical() != options.Regex {
+			continue
+		}
+		if a.Name == "" {
+			a.Name = strconv.Itoa(i)
+		}
+		for _, flag := range flags {
+			for _, p := range a.Patterns {
+				expanded, err := options.ExpandRegexPattern(p, func(steps []string) (string, error) {
+					return GenerateTransformAlias(options.Alias{Transforms: steps}, flag)
+				})
+				if err != nil {
+					return nil, fmt.Errorf("regex '%s': could not expand pattern '%s': %w", a.Name, p, err)
+				}
+				pattern, err := regexp.Compile(expanded)
+				if err != nil {
+					return nil, fmt.Errorf("regex '%s': could not compile pattern '%s': %w", a.Name, p, err)
+				}
+				ret[flag] = append(ret[flag], pattern)
+			}
+		}
+	}
+	return ret, nil
+}
+
 func GenerateNamingConventionAlias(a options.Alias, flag string) (alias string, err error) {
-	switch a.Type.Canonical() {
+	alias, err = applyNamingConvention(a.Type, flag)
+	if err != nil {
+		return "", err
+	}
+	return a.Prefix + alias + a.Suffix, nil
+}
+
+// GenerateTransformAlias applies the transforms of an alias to a flag key in order, then adds the prefix and suffix
+func GenerateTransformAlias(a options.Alias, flag string) (alias string, err error) {
+	delimiter := a.NamespaceDelimiter
+	if delimiter == "" {
+		delimiter = options.DefaultNamespaceDelimiter
+	}
+
+	alias = flag
+	for _, step := range a.Transforms {
+		switch strings.ToLower(step) {
+		case options.LowercaseTransform:
+			alias = strings.ToLower(alias)
+		case options.UppercaseTransform:
+			alias = strings.ToUpper(alias)
+		case options.StripNamespaceTransform:
+			if i := strings.LastIndex(alias, delimiter); i >= 0 {
+				alias = alias[i+len(delimiter):]
+			}
+		default:
+			alias, err = applyNamingConvention(options.AliasType(step), alias)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return a.Prefix + alias + a.Suffix, nil
+}
+
+func applyNamingConvention(t options.AliasType, flag string) (alias string, err error) {
+	switch t.Canonical() {
 	case options.CamelCase:
 		alias = strcase.ToLowerCamel(flag)
 	case options.PascalCase:
@@ -77,31 +193,31 @@ func GenerateNamingConventionAlias(a options.Alias, flag string) (alias string,
 	case options.DotCase:
 		alias = strcase.ToDelimited(flag, '.')
 	default:
-		err = fmt.Errorf("naming convention alias type %s not recognized", a.Type)
+		err = fmt.Errorf("naming convention alias type %s not recognized", t)
 	}
 
 	return alias, err
 }
 
-func GenerateAliasesFromFilePattern(a options.Alias, flag, dir string, allFileContents FileContentsMap) ([]string, error) {
+func GenerateAliasesFromFilePattern(a options.Alias, flag, dir string, files fs.FS, allFileContents FileContentsMap) ([]string, error) {
 	ret := []string{}
 	// Concatenate the contents of all files into a single byte array to be matched by specified patterns
 	fileContents := []byte{}
-	for _, path := range a.Paths {
-		absGlob := filepath.Join(dir, path)
-		matches, err := doublestar.FilepathGlob(absGlob)
-		if err != nil {
-			return nil, fmt.Errorf("filepattern '%s': could not process path glob '%s'", a.Name, absGlob)
-		}
-		for _, match := range matches {
-			if pathFileContents := allFileContents[match]; len(pathFileContents) > 0 {
-				fileContents = append(fileContents, pathFileContents...)
-			}
+	paths, err := resolveAliasPaths(a, a.Name, dir, files)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if pathFileContents := allFileContents[path]; len(pathFileContents) > 0 {
+			fileContents = append(fileContents, pathFileContents...)
 		}
 	}
 
 	for _, p := range a.Patterns {
-		pattern := regexp.MustCompile(strings.ReplaceAll(p, "FLAG_KEY", flag))
+		pattern, err := regexp.Compile(strings.ReplaceAll(p, "FLAG_KEY", flag))
+		if err != nil {
+			return nil, fmt.Errorf("filepattern '%s': could not compile pattern '%s': %w", a.Name, p, err)
+		}
 		results := pattern.FindAllStringSubmatch(string(fileContents), -1)
 		for _, res := range results {
 			if len(res) > 1 {
@@ -113,40 +229,12 @@ func GenerateAliasesFromFilePattern(a options.Alias, flag, dir string, allFileCo
 	return ret, nil
 }
 
-func GenerateAliasesFromCommand(a options.Alias, flag, dir string) ([]string, error) {
-	ret := []string{}
-	ctx := context.Background()
-	if a.Timeout != nil && *a.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithDeadline(ctx, time.Now().Add(time.Second*time.Duration(*a.Timeout)))
-		defer cancel()
-	}
-	tokens := strings.Split(*a.Command, " ")
-	name := tokens[0]
-	args := []string{}
-	if len(tokens) > 1 {
-		args = tokens[1:]
-	}
-	/* #nosec */
-	cmd := exec.CommandContext(ctx, name, args...)
-	cmd.Stdin = strings.NewReader(flag)
-	cmd.Dir = dir
-	stdout, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("command '%s': failed to execute alias command: %w", a.Name, err)
-	}
-	if err := json.Unmarshal(stdout, &ret); err != nil {
-		return nil, fmt.Errorf("command '%s': could not unmarshal json output of alias command: %w", a.Name, err)
-	}
-
-	return ret, err
-}
-
-// processFileContent reads and stores the content of files specified by filePattern alias matchers to be matched for aliases
-func processFileContent(aliases []options.Alias, dir string) (FileContentsMap, error) {
+// processFileContent reads and stores the content of files specified by filePattern and structured alias matchers
+// to be matched for aliases. Contents are keyed by the path of the file joined to dir.
+func processFileContent(aliases []options.Alias, dir string, files fs.FS) (FileContentsMap, error) {
 	allFileContents := map[string][]byte{}
 	for idx, a := range aliases {
-		if a.Type.Canonical() != options.FilePattern {
+		if !readsFiles(a) {
 			continue
 		}
 
@@ -155,19 +243,13 @@ func processFileContent(aliases []options.Alias, dir string) (FileContentsMap, e
 			aliasId = a.Name
 		}
 
-		paths := []string{}
-		for _, glob := range a.Paths {
-			absGlob := filepath.Join(dir, glob)
-			matches, err := doublestar.FilepathGlob(absGlob)
-			if err != nil {
-				return nil, fmt.Errorf("filepattern '%s': could not process path glob '%s'", aliasId, absGlob)
-			}
-			if matches == nil {
-				log.Info.Printf("filepattern '%s': no matching files found for alias path glob '%s'", aliasId, absGlob)
-			}
-			paths = append(paths, matches...)
+		paths, err := resolveAliasPaths(a, aliasId, dir, files)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			log.Info.Printf("%s '%s': no matching files found for alias paths %q", a.Type, aliasId, a.Paths)
 		}
-		paths = helpers.Dedupe(paths)
 
 		for _, path := range paths {
 			_, pathAlreadyProcessed := allFileContents[path]
@@ -175,16 +257,63 @@ func processFileContent(aliases []options.Alias, dir string) (FileContentsMap, e
 				continue
 			}
 
-			if !validation.FileExists(path) {
-				return nil, fmt.Errorf("filepattern '%s': could not find file at path '%s'", aliasId, path)
-			}
-			/* #nosec */
-			data, err := os.ReadFile(path)
+			data, err := fs.ReadFile(files, relativeAliasPath(dir, path))
 			if err != nil {
-				return nil, fmt.Errorf("filepattern '%s': could not process file at path '%s': %v", aliasId, path, err)
+				return nil, fmt.Errorf("%s '%s': could not process file at path '%s': %v", a.Type, aliasId, path, err)
 			}
 			allFileContents[path] = data
 		}
 	}
 	return allFileContents, nil
 }
+
+// ReadsFiles reports whether any of aliases reads files, rather than only flag keys or command output
+func ReadsFiles(aliases []options.Alias) bool {
+	for _, a := range aliases {
+		if readsFiles(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func readsFiles(a options.Alias) bool {
+	switch a.Type.Canonical() {
+	case options.FilePattern, options.Structured, options.Constants:
+		return true
+	}
+	return false
+}
+
+// resolveAliasPaths returns the paths, joined to dir, of the files matching the path globs of an alias
+func resolveAliasPaths(a options.Alias, aliasId, dir string, files fs.FS) ([]string, error) {
+	paths := []string{}
+	for _, glob := range a.Paths {
+		// globs are relative to the root of files
+		relGlob := strings.TrimPrefix(path.Clean(filepath.ToSlash(glob)), "/")
+		matches, err := doublestar.Glob(files, relGlob, doublestar.WithFilesOnly())
+		if err != nil {
+			return nil, fmt.Errorf("%s '%s': could not process path glob '%s'", a.Type, aliasId, glob)
+		}
+		for _, match := range matches {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(match)))
+		}
+	}
+	return helpers.Dedupe(paths), nil
+}
+
+// relativeAliasPath returns the slash separated path of an alias file relative to dir, as read from the file system
+func relativeAliasPath(dir, path string) string {
+	if rel, err := filepath.Rel(workingDir(dir), path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// workingDir returns dir, or the current directory if it is empty
+func workingDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
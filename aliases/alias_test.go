@@ -1,6 +1,7 @@
 package aliases
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -89,6 +90,34 @@ func Test_GenerateAliases(t *testing.T) {
 			},
 			want: map[string][]string{"SOME_FLAG": slice("someFlag")},
 		},
+		{
+			name:  "naming convention with prefix",
+			flags: slice(testFlagKey),
+			aliases: []o.Alias{
+				{Type: o.UpperSnakeCase, Prefix: "FEATURE_"},
+			},
+			want: map[string][]string{testFlagKey: slice("FEATURE_SOME_FLAG")},
+		},
+		{
+			name:  "transform with prefix and suffix",
+			flags: slice(testFlagKey),
+			aliases: []o.Alias{
+				{Type: o.Transform, Transforms: slice("pascalcase"), Prefix: "use", Suffix: "Flag"},
+			},
+			want: map[string][]string{testFlagKey: slice("useSomeFlagFlag")},
+		},
+		{
+			name:  "transform pipeline stripping a namespace",
+			flags: slice("checkout.new-flow", "checkout::legacy-flow"),
+			aliases: []o.Alias{
+				{Type: o.Transform, Transforms: slice("stripnamespace", "uppersnakecase")},
+				{Type: o.Transform, Transforms: slice("stripnamespace", "camelcase"), NamespaceDelimiter: "::"},
+			},
+			want: map[string][]string{
+				"checkout.new-flow":     slice("NEW_FLOW", "checkoutNewFlow"),
+				"checkout::legacy-flow": slice("CHECKOUT::LEGACY_FLOW", "legacyFlow"),
+			},
+		},
 		{
 			name:  "file exact pattern",
 			flags: slice(testFlagKey),
@@ -105,14 +134,52 @@ func Test_GenerateAliases(t *testing.T) {
 			},
 			want: map[string][]string{testWildFlagKey: slice("WILD_FLAG", "WILD_FLAG_SECOND_ALIAS"), testFlagKey: slice("SOME_FLAG")},
 		},
+		{
+			name:  "structured json keys",
+			flags: slice("new-checkout", "legacy-checkout", "dark-mode"),
+			aliases: []o.Alias{
+				{Type: o.Structured, Paths: slice("testdata/structured/*.json"), Query: ".features.* == FLAG_KEY"},
+			},
+			want: map[string][]string{"new-checkout": slice("NEW_CHECKOUT"), "legacy-checkout": slice("LEGACY_CHECKOUT"), "dark-mode": slice()},
+		},
+		{
+			name:  "structured yaml and toml",
+			flags: slice("new-checkout", "dark-mode", "fast-search"),
+			aliases: []o.Alias{
+				{Type: o.Structured, Paths: slice("testdata/structured/*.yaml"), Query: ".flags.*.key == FLAG_KEY -> .constant"},
+				{Type: o.Structured, Paths: slice("testdata/structured/*.toml"), Query: ".features.*.key == FLAG_KEY"},
+			},
+			want: map[string][]string{"new-checkout": slice("NewCheckoutFlag", "checkout"), "dark-mode": slice("DarkModeFlag"), "fast-search": slice("search")},
+		},
+		{
+			name:  "constants",
+			flags: slice("new-checkout", "dark-mode", "legacy-checkout", "fast-search"),
+			aliases: []o.Alias{
+				{Type: o.Constants, Paths: slice("testdata/constants/*")},
+			},
+			want: map[string][]string{
+				"new-checkout":    slice("NewCheckout", "featureflags.NewCheckout", "NEW_CHECKOUT"),
+				"dark-mode":       slice("DarkMode", "featureflags.DarkMode", "Flags.DARK_MODE", "Flags.DarkMode"),
+				"legacy-checkout": slice("LEGACY_CHECKOUT", "FlagKeys.checkout.legacy", "Legacy.LEGACY"),
+				"fast-search":     slice(`FlagKeys["quoted-key"]`, `FlagKeys['quoted-key']`, "SEARCH"),
+			},
+		},
 		{
 			name:  "command",
 			flags: slice(testFlagKey),
 			aliases: []o.Alias{
-				cmd(`echo ["SOME_FLAG"]`, 0),
+				cmd(`echo '["SOME_FLAG"]'`, 0),
 			},
 			want: map[string][]string{testFlagKey: slice("SOME_FLAG")},
 		},
+		{
+			name:  "batch command",
+			flags: slice(testFlagKey, testFlagKey2),
+			aliases: []o.Alias{
+				batchCmd(`sh -c 'read -r keys; echo "{\"someFlag\": $keys, \"anotherFlag\": [\"ANOTHER_FLAG\"]}"'`, 5),
+			},
+			want: map[string][]string{testFlagKey: slice(testFlagKey, testFlagKey2), testFlagKey2: slice("ANOTHER_FLAG")},
+		},
 		{
 			name:  "command, custom pascalcase",
 			flags: slice("some_flag"),
@@ -125,7 +192,7 @@ func Test_GenerateAliases(t *testing.T) {
 
 	for _, tt := range specs {
 		t.Run(tt.name, func(t *testing.T) {
-			aliases, err := GenerateAliases(tt.flags, tt.aliases, "")
+			aliases, err := GenerateAliases(context.Background(), tt.flags, tt.aliases, "", nil)
 			require.NoError(t, err)
 			assert.Equal(t, tt.want, aliases)
 		})
@@ -189,7 +256,7 @@ func Test_processFileContent(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			aliases, err := processFileContent(tt.aliases, tt.dir)
+			aliases, err := processFileContent(tt.aliases, tt.dir, os.DirFS(tt.dir))
 			assert.Equal(t, tt.want, aliases)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("processFileContent error = %v, wantErr %v", err, tt.wantErr)
@@ -244,3 +311,62 @@ func cmd(command string, timeout int64) o.Alias {
 	a.Timeout = &timeout
 	return a
 }
+
+func batchCmd(command string, timeout int64) o.Alias {
+	a := cmd(command, timeout)
+	a.Batch = true
+	return a
+}
+
+func Test_splitCommand(t *testing.T) {
+	specs := []struct {
+		command string
+		want    []string
+	}{
+		{command: "node  alias.js\t--all", want: slice("node", "alias.js", "--all")},
+		{command: `echo '["SOME FLAG"]'`, want: slice("echo", `["SOME FLAG"]`)},
+		{command: `sh -c "echo \"a b\" \$HOME"`, want: slice("sh", "-c", `echo "a b" $HOME`)},
+		{command: `./my\ script.sh a'b'"c"`, want: slice("./my script.sh", "abc")},
+		{command: `echo ''`, want: slice("echo", "")},
+	}
+	for _, tt := range specs {
+		got, err := splitCommand(tt.command)
+		require.NoError(t, err, tt.command)
+		assert.Equal(t, tt.want, got, tt.command)
+	}
+
+	_, err := splitCommand(`echo 'unterminated`)
+	require.Error(t, err)
+	_, err = splitCommand(`echo "unterminated`)
+	require.Error(t, err)
+}
+
+func Test_GenerateRegexAliases(t *testing.T) {
+	aliases := []o.Alias{
+		{Type: o.Regex, Patterns: []string{`FF_FLAG_KEY_V\d+`}},
+		alias(o.CamelCase),
+	}
+	got, err := GenerateRegexAliases([]string{"my.flag"}, aliases)
+	require.NoError(t, err)
+	require.Len(t, got["my.flag"], 1)
+	require.True(t, got["my.flag"][0].MatchString("FF_my.flag_V2"))
+	// the flag key is quoted, so '.' only matches itself
+	require.False(t, got["my.flag"][0].MatchString("FF_myXflag_V2"))
+
+	transformed, err := GenerateRegexAliases([]string{"new-checkout"}, []o.Alias{
+		{Type: o.Regex, Patterns: []string{`FF_{FLAG_KEY:upper_snake}_V\d+`, `(?i)flag:{FLAG_KEY}`}},
+	})
+	require.NoError(t, err)
+	require.Len(t, transformed["new-checkout"], 2)
+	require.True(t, transformed["new-checkout"][0].MatchString("FF_NEW_CHECKOUT_V2"))
+	require.False(t, transformed["new-checkout"][0].MatchString("FF_new-checkout_V2"))
+	require.True(t, transformed["new-checkout"][1].MatchString("FLAG:New-Checkout"))
+
+	_, err = GenerateRegexAliases([]string{"new-checkout"}, []o.Alias{{Type: o.Regex, Patterns: []string{`{FLAG_KEY:shouting}`}}})
+	require.Error(t, err)
+
+	// regex aliases do not generate static aliases
+	static, err := GenerateAliases(context.Background(), []string{"my.flag"}, aliases[:1], "", nil)
+	require.NoError(t, err)
+	require.Empty(t, static["my.flag"])
+}
@@ -0,0 +1,128 @@
+package aliases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+func GenerateAliasesFromCommand(ctx context.Context, a options.Alias, flag, dir string) ([]string, error) {
+	ret := []string{}
+	stdout, err := runAliasCommand(ctx, a, dir, flag)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stdout, &ret); err != nil {
+		return nil, fmt.Errorf("command '%s': could not unmarshal json output of alias command: %w", a.Name, err)
+	}
+
+	return ret, err
+}
+
+// GenerateAliasesFromCommandBatch runs the command of a batch alias once for all flags. The command receives a JSON
+// array of flag keys as standard input, and is expected to output a JSON object mapping flag keys to their aliases.
+func GenerateAliasesFromCommandBatch(ctx context.Context, a options.Alias, flags []string, dir string) (map[string][]string, error) {
+	input, err := json.Marshal(flags)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := runAliasCommand(ctx, a, dir, string(input))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]string{}
+	if err := json.Unmarshal(stdout, &ret); err != nil {
+		return nil, fmt.Errorf("command '%s': could not unmarshal json output of batch alias command: %w", a.Name, err)
+	}
+	return ret, nil
+}
+
+// runAliasCommand runs the command of an alias with stdin as standard input, and returns its standard output.
+// The timeout of the alias applies to the whole run of the command.
+func runAliasCommand(ctx context.Context, a options.Alias, dir, stdin string) ([]byte, error) {
+	if a.Timeout != nil && *a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, time.Now().Add(time.Second*time.Duration(*a.Timeout)))
+		defer cancel()
+	}
+	tokens, err := splitCommand(*a.Command)
+	if err != nil {
+		return nil, fmt.Errorf("command '%s': could not parse alias command: %w", a.Name, err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("command '%s': alias command is empty", a.Name)
+	}
+	/* #nosec */
+	cmd := exec.CommandContext(ctx, tokens[0], tokens[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Dir = dir
+	stdout, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command '%s': failed to execute alias command: %w", a.Name, err)
+	}
+	return stdout, nil
+}
+
+// splitCommand splits a command into arguments following POSIX shell quoting rules: arguments are separated by
+// unquoted whitespace, single quotes preserve their content literally, and backslashes escape the next character
+// outside of quotes and '"', '\', '$' and '`' within double quotes. No other shell expansion is performed.
+func splitCommand(command string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			inArg = true
+			if i+1 < len(runes) {
+				i++
+				arg.WriteRune(runes[i])
+			}
+		case r == '\'':
+			inArg = true
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("unterminated single quote")
+			}
+			arg.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inArg = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				arg.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+		default:
+			inArg = true
+			arg.WriteRune(r)
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,398 @@
+package aliases
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/growthbook/gb-find-code-refs/internal/helpers"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+// constantDecl is a declared identifier whose value is a string literal
+type constantDecl struct {
+	names []string // the identifier, and its qualified names
+	value string
+}
+
+// GenerateAliasesFromConstants finds constant, enum and object literal declarations with string values in the Go,
+// TypeScript, JavaScript and Python files matching the paths of a constants alias. The declared identifiers are
+// returned keyed by their value, so that declarations whose value is a flag key become aliases of the flag.
+func GenerateAliasesFromConstants(a options.Alias, dir string, files fs.FS, allFileContents FileContentsMap) (map[string][]string, error) {
+	paths, err := resolveAliasPaths(a, a.Name, dir, files)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]string{}
+	for _, path := range paths {
+		var decls []constantDecl
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".go":
+			decls, err = parseGoConstants(path, allFileContents[path])
+			if err != nil {
+				log.Warning.Printf("constants '%s': skipping file at path '%s' that could not be parsed: %s", a.Name, path, err)
+				continue
+			}
+		case ".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs":
+			decls = parseJSConstants(allFileContents[path])
+		case ".py":
+			decls = parsePythonConstants(allFileContents[path])
+		default:
+			log.Debug.Printf("constants '%s': skipping file at path '%s' with unsupported extension", a.Name, path)
+			continue
+		}
+		for _, decl := range decls {
+			if decl.value == "" {
+				continue
+			}
+			ret[decl.value] = helpers.Dedupe(append(ret[decl.value], decl.names...))
+		}
+	}
+	return ret, nil
+}
+
+// parseGoConstants returns the package level constants and variables initialized with string literals. Each is
+// registered by name and qualified by package name.
+func parseGoConstants(path string, src []byte) ([]constantDecl, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	decls := []constantDecl{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || (genDecl.Tok != token.CONST && genDecl.Tok != token.VAR) {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				if i >= len(valueSpec.Values) || name.Name == "_" {
+					continue
+				}
+				lit, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				value, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					continue
+				}
+				decls = append(decls, constantDecl{names: []string{name.Name, file.Name.Name + "." + name.Name}, value: value})
+			}
+		}
+	}
+	return decls, nil
+}
+
+type jsTokenKind int
+
+const (
+	jsIdent jsTokenKind = iota
+	jsString
+	jsPunct
+)
+
+type jsToken struct {
+	kind  jsTokenKind
+	value string
+}
+
+// jsNamespace is a namespace whose body is at depth
+type jsNamespace struct {
+	name  string
+	depth int
+}
+
+// parseJSConstants returns the string constants declared with const, let or var, the members of enums, and the
+// properties of object literals assigned to constants, qualified by the enclosing namespace, enum or object names.
+// Only top level and exported declarations are returned, not the local variables of functions.
+func parseJSConstants(src []byte) []constantDecl {
+	tokens := tokenizeJS(string(src))
+	decls := []constantDecl{}
+	depth := 0
+	namespaces := []jsNamespace{}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.value {
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			depth--
+			if len(namespaces) > 0 && depth < namespaces[len(namespaces)-1].depth {
+				namespaces = namespaces[:len(namespaces)-1]
+			}
+		}
+		if tok.kind != jsIdent || i+1 >= len(tokens) || tokens[i+1].kind != jsIdent {
+			continue
+		}
+		// declarations may only be exported at the top level or in the body of a namespace
+		if depth > 0 && (i == 0 || tokens[i-1].value != "export") {
+			continue
+		}
+		name := tokens[i+1].value
+		if len(namespaces) > 0 {
+			name = namespaces[len(namespaces)-1].name + "." + name
+		}
+		switch tok.value {
+		case "namespace", "module":
+			// dotted names declare nested namespaces, e.g. namespace Flags.Legacy { ... }
+			j := i + 2
+			for j+1 < len(tokens) && tokens[j].value == "." && tokens[j+1].kind == jsIdent {
+				name += "." + tokens[j+1].value
+				j += 2
+			}
+			if j < len(tokens) && tokens[j].value == "{" {
+				namespaces = append(namespaces, jsNamespace{name: name, depth: depth + 1})
+			}
+		case "const", "let", "var":
+			j := i + 2
+			// skip type annotations
+			if j < len(tokens) && tokens[j].value == ":" {
+				for j < len(tokens) && tokens[j].value != "=" && tokens[j].value != ";" {
+					j++
+				}
+			}
+			if j+1 >= len(tokens) || tokens[j].value != "=" {
+				continue
+			}
+			switch value := tokens[j+1]; {
+			case value.kind == jsString:
+				decls = append(decls, constantDecl{names: []string{name}, value: value.value})
+			case value.value == "{":
+				decls = append(decls, parseJSObject(tokens, j+1, name)...)
+			}
+		case "enum":
+			if i+2 < len(tokens) && tokens[i+2].value == "{" {
+				decls = append(decls, parseJSObject(tokens, i+2, name)...)
+			}
+		}
+	}
+	return decls
+}
+
+// parseJSObject returns the string properties of the object literal or enum body starting at tokens[start], with
+// nested objects qualified by their property names. Properties whose names are not identifiers are qualified with
+// brackets, as they must be accessed, e.g. FlagKeys["quoted-key"] and FlagKeys['quoted-key'].
+func parseJSObject(tokens []jsToken, start int, prefix string) []constantDecl {
+	decls := []constantDecl{}
+	var parse func(i int, prefix string) int
+	parse = func(i int, prefix string) int {
+		// tokens[i] is the opening brace
+		i++
+		for i < len(tokens) && tokens[i].value != "}" {
+			key := tokens[i]
+			if (key.kind != jsIdent && key.kind != jsString) || i+2 >= len(tokens) ||
+				(tokens[i+1].value != ":" && tokens[i+1].value != "=") {
+				i = skipJSValue(tokens, i)
+				continue
+			}
+			qualified := []string{prefix + "." + key.value}
+			if !isJSIdentifier(key.value) {
+				qualified = []string{prefix + `["` + key.value + `"]`, prefix + "['" + key.value + "']"}
+			}
+			value := tokens[i+2]
+			switch {
+			case value.kind == jsString && (i+3 >= len(tokens) || tokens[i+3].value == "," || tokens[i+3].value == "}"):
+				decls = append(decls, constantDecl{names: qualified, value: value.value})
+				i += 3
+			case value.value == "{":
+				i = parse(i+2, qualified[0]) + 1
+			default:
+				i = skipJSValue(tokens, i+2)
+				continue
+			}
+			if i < len(tokens) && tokens[i].value == "," {
+				i++
+			}
+		}
+		return i
+	}
+	parse(start, prefix)
+	return decls
+}
+
+// isJSIdentifier reports whether name may be used as an identifier, and so accessed as a property with a dot
+func isJSIdentifier(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// skipJSValue returns the index after the next comma at the current nesting depth, or the index of the closing
+// brace of the enclosing object
+func skipJSValue(tokens []jsToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].value {
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ",":
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// tokenizeJS splits TypeScript or JavaScript source into identifiers, string literals and punctuation, skipping
+// comments. Template literals with substitutions and regular expression literals are treated as punctuation so
+// they are never matched as values.
+func tokenizeJS(src string) []jsToken {
+	tokens := []jsToken{}
+	runes := []rune(src)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case r == '/' && startsJSRegex(tokens):
+			if end := skipJSRegex(runes, i); end > i {
+				i = end
+				tokens = append(tokens, jsToken{kind: jsPunct, value: "/"})
+			} else {
+				tokens = append(tokens, jsToken{kind: jsPunct, value: string(r)})
+			}
+		case r == '"' || r == '\'' || r == '`':
+			var sb strings.Builder
+			hasSubstitution := false
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				} else if r == '`' && runes[i] == '$' && i+1 < len(runes) && runes[i+1] == '{' {
+					hasSubstitution = true
+				}
+				sb.WriteRune(runes[i])
+			}
+			if hasSubstitution {
+				tokens = append(tokens, jsToken{kind: jsPunct, value: "`"})
+			} else {
+				tokens = append(tokens, jsToken{kind: jsString, value: sb.String()})
+			}
+		case r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			start := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || runes[i+1] == '$' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			tokens = append(tokens, jsToken{kind: jsIdent, value: string(runes[start : i+1])})
+		default:
+			tokens = append(tokens, jsToken{kind: jsPunct, value: string(r)})
+		}
+	}
+	return tokens
+}
+
+// jsRegexKeywords are the keywords after which a slash starts a regular expression rather than a division
+var jsRegexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true, "in": true, "of": true,
+	"instanceof": true, "new": true, "delete": true, "void": true, "throw": true, "yield": true, "await": true,
+}
+
+// startsJSRegex reports whether a slash following tokens starts a regular expression literal, that is whether it
+// follows an operator or keyword rather than a value
+func startsJSRegex(tokens []jsToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	prev := tokens[len(tokens)-1]
+	switch prev.kind {
+	case jsPunct:
+		return prev.value != ")" && prev.value != "]" && prev.value != "}" && prev.value != "`"
+	case jsIdent:
+		return jsRegexKeywords[prev.value]
+	}
+	return false
+}
+
+// skipJSRegex returns the index of the last flag of the regular expression literal starting at runes[start], or
+// start if the literal is not terminated on the same line
+func skipJSRegex(runes []rune, start int) int {
+	inClass := false
+	for i := start + 1; i < len(runes) && runes[i] != '\n'; i++ {
+		switch {
+		case runes[i] == '\\':
+			i++
+		case runes[i] == '[':
+			inClass = true
+		case runes[i] == ']':
+			inClass = false
+		case runes[i] == '/' && !inClass:
+			for i+1 < len(runes) && unicode.IsLetter(runes[i+1]) {
+				i++
+			}
+			return i
+		}
+	}
+	return start
+}
+
+var (
+	pythonClassRegex      = regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`)
+	pythonAssignmentRegex = regexp.MustCompile(`^\s*([A-Za-z_]\w*)\s*(?::[^=]+)?=\s*[rbuRBU]?(?:"([^"\\]*)"|'([^'\\]*)')\s*(?:#.*)?$`)
+)
+
+// parsePythonConstants returns the module level variables assigned string literals, and the class attributes
+// assigned string literals (such as enum members) qualified by class name
+func parsePythonConstants(src []byte) []constantDecl {
+	decls := []constantDecl{}
+	class, classIndent := "", ""
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if indent == "" {
+			class, classIndent = "", ""
+			if match := pythonClassRegex.FindStringSubmatch(line); match != nil {
+				class = match[1]
+				continue
+			}
+		} else if class != "" && classIndent == "" {
+			classIndent = indent
+		}
+
+		match := pythonAssignmentRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		value := match[2] + match[3]
+		switch {
+		case indent == "":
+			decls = append(decls, constantDecl{names: []string{match[1]}, value: value})
+		case class != "" && indent == classIndent:
+			// attributes of the class body, but not variables of its methods
+			decls = append(decls, constantDecl{names: []string{class + "." + match[1]}, value: value})
+		}
+	}
+	return decls
+}
@@ -0,0 +1,139 @@
+package aliases
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+// GenerateAliasesFromStructuredData evaluates the query of a structured alias against each JSON, YAML or TOML file
+// matching its paths, and returns the selected aliases keyed by the flag key they were matched against
+func GenerateAliasesFromStructuredData(a options.Alias, dir string, files fs.FS, allFileContents FileContentsMap) (map[string][]string, error) {
+	query, err := options.ParseDataQuery(a.Query)
+	if err != nil {
+		return nil, fmt.Errorf("structured '%s': %w", a.Name, err)
+	}
+	paths, err := resolveAliasPaths(a, a.Name, dir, files)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]string{}
+	for _, path := range paths {
+		doc, err := parseDataFile(path, allFileContents[path])
+		if err != nil {
+			return nil, fmt.Errorf("structured '%s': could not parse file at path '%s': %w", a.Name, path, err)
+		}
+		matchDataPath(doc, query, 0, "", nil, func(value, key string, keyNode interface{}) {
+			alias := key
+			if query.Select != nil {
+				selected, ok := lookupDataPath(keyNode, query.Select).(string)
+				if !ok {
+					return
+				}
+				alias = selected
+			}
+			ret[value] = append(ret[value], alias)
+		})
+	}
+	return ret, nil
+}
+
+func parseDataFile(path string, data []byte) (doc interface{}, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		err = fmt.Errorf("unsupported file extension '%s', expected .json, .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	return doc, err
+}
+
+// matchDataPath walks the nodes of doc matching query.Path, and calls visit with each string value at the end of
+// the path, along with the key and node matched by the last wildcard
+func matchDataPath(node interface{}, query options.DataQuery, depth int, key string, keyNode interface{}, visit func(value, key string, keyNode interface{})) {
+	if depth == len(query.Path) {
+		if value, ok := node.(string); ok {
+			visit(value, key, keyNode)
+		}
+		return
+	}
+
+	segment := query.Path[depth]
+	if segment != "*" {
+		if child := dataChild(node, segment); child != nil {
+			matchDataPath(child, query, depth+1, key, keyNode, visit)
+		}
+		return
+	}
+
+	isKeyWildcard := depth == query.WildcardIndex()
+	for _, childKey := range dataKeys(node) {
+		child := dataChild(node, childKey)
+		if isKeyWildcard {
+			matchDataPath(child, query, depth+1, childKey, child, visit)
+		} else {
+			matchDataPath(child, query, depth+1, key, keyNode, visit)
+		}
+	}
+}
+
+func lookupDataPath(node interface{}, path []string) interface{} {
+	for _, key := range path {
+		node = dataChild(node, key)
+	}
+	return node
+}
+
+// dataKeys returns the sorted keys of an object, or the indexes of an array
+func dataKeys(node interface{}) []string {
+	keys := []string{}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	case map[interface{}]interface{}:
+		for k := range n {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+	case []interface{}:
+		for i := range n {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	}
+	return keys
+}
+
+func dataChild(node interface{}, key string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		return n[key]
+	case map[interface{}]interface{}:
+		for k, v := range n {
+			if fmt.Sprint(k) == key {
+				return v
+			}
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n) {
+			return n[i]
+		}
+	}
+	return nil
+}
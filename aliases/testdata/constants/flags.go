@@ -0,0 +1,13 @@
+package featureflags
+
+const (
+	NewCheckout = "new-checkout"
+	MaxRetries  = 3
+)
+
+var DarkMode, _ = "dark-mode", "unused"
+
+func enabled() string {
+	const local = "not-a-package-constant"
+	return local
+}
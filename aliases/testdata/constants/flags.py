@@ -0,0 +1,13 @@
+from enum import Enum
+
+NEW_CHECKOUT = "new-checkout"
+TIMEOUT: int = 5
+
+class Flags(Enum):
+    DARK_MODE = 'dark-mode'
+
+    def local(self):
+        ignored = "fast-search"
+        return ignored
+
+LEGACY_CHECKOUT: str = "legacy-checkout"  # deprecated
@@ -0,0 +1,29 @@
+// const Commented = "new-checkout"
+export const NEW_CHECKOUT: string = "new-checkout";
+
+export enum Flags {
+  DarkMode = 'dark-mode',
+  Count = 1,
+  Implicit,
+}
+
+export const FlagKeys = {
+  checkout: {
+    legacy: "legacy-checkout",
+    templated: `${prefix}-checkout`,
+  },
+  isOn(key: string) { return key === "ignored" },
+  "quoted-key": "fast-search",
+} as const;
+
+export namespace Legacy {
+  export const LEGACY = "legacy-checkout";
+}
+
+const quotes = /["'`]/g;
+export const SEARCH = "fast-search";
+
+function isDarkMode(): boolean {
+  const local = "dark-mode";
+  return isOn(local);
+}
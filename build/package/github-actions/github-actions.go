@@ -20,7 +20,9 @@ func main() {
 		log.Error.Fatal(err)
 	}
 	log.Init(opts.Debug)
-	coderefs.Run(opts, true)
+	if err := coderefs.Run(opts, true); err != nil {
+		log.Error.Fatal(err)
+	}
 }
 
 // mergeGithubOptions sets inferred options from the github actions environment, when available
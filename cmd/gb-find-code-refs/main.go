@@ -14,7 +14,7 @@ import (
 var extinctions = &cobra.Command{
 	Use:     "extinctions",
 	Example: "gb-find-code-refs extinctions",
-	Short:   "Find and Post extinctions for branch",
+	Short:   "Find extinctions for branch and write them to a separate file",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		err := o.InitYAML()
 		if err != nil {
@@ -31,8 +31,113 @@ var extinctions = &cobra.Command{
 		}
 
 		log.Init(opts.Debug)
-		coderefs.Run(opts, true)
-		return nil
+		// errors from here on are not caused by invalid usage
+		cmd.SilenceUsage = true
+		return coderefs.Run(opts, true)
+	},
+}
+
+var check = &cobra.Command{
+	Use:     "check",
+	Example: "gb-find-code-refs check",
+	Short:   "Check code references against the policy configured in .growthbook/coderefs.yaml, exiting non-zero on violations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := o.InitYAML()
+		if err != nil {
+			return err
+		}
+
+		opts, err := o.GetOptions()
+		if err != nil {
+			return err
+		}
+		err = opts.Validate()
+		if err != nil {
+			return err
+		}
+
+		log.Init(opts.Debug)
+		// errors from here on are not caused by invalid usage
+		cmd.SilenceUsage = true
+		return coderefs.RunCheck(opts)
+	},
+}
+
+var stale = &cobra.Command{
+	Use:     "stale",
+	Example: "gb-find-code-refs stale --staleLookback=5000",
+	Short:   "List flags without references and the commit that last removed each one, and write them to a separate file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := o.InitYAML()
+		if err != nil {
+			return err
+		}
+
+		opts, err := o.GetOptions()
+		if err != nil {
+			return err
+		}
+		err = opts.Validate()
+		if err != nil {
+			return err
+		}
+
+		log.Init(opts.Debug)
+		// errors from here on are not caused by invalid usage
+		cmd.SilenceUsage = true
+		return coderefs.RunStale(opts)
+	},
+}
+
+var history = &cobra.Command{
+	Use:     "history",
+	Example: "gb-find-code-refs history --since=52w --historyInterval=10 --format=csv",
+	Short:   "Count references to each flag across git history and write the time series to a separate file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := o.InitYAML()
+		if err != nil {
+			return err
+		}
+
+		opts, err := o.GetOptions()
+		if err != nil {
+			return err
+		}
+		err = opts.Validate()
+		if err != nil {
+			return err
+		}
+
+		log.Init(opts.Debug)
+		// errors from here on are not caused by invalid usage
+		cmd.SilenceUsage = true
+		return coderefs.RunHistory(opts)
+	},
+}
+
+var branches = &cobra.Command{
+	Use:     "branches",
+	Example: "gb-find-code-refs branches --branchGlob='release/*' --branchMaxAge=30d",
+	Short:   "Scan each remote branch without checking it out, and list the branches deleted from the remote",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := o.InitYAML()
+		if err != nil {
+			return err
+		}
+
+		opts, err := o.GetOptions()
+		if err != nil {
+			return err
+		}
+		err = opts.Validate()
+		if err != nil {
+			return err
+		}
+
+		log.Init(opts.Debug)
+		// errors from here on are not caused by invalid usage
+		cmd.SilenceUsage = true
+		return coderefs.RunBranches(opts)
 	},
 }
 
@@ -54,8 +159,9 @@ var cmd = &cobra.Command{
 		}
 
 		log.Init(opts.Debug)
-		coderefs.Run(opts, false)
-		return nil
+		// errors from here on are not caused by invalid usage
+		cmd.SilenceUsage = true
+		return coderefs.Run(opts, false)
 	},
 	Version: version.Version,
 }
@@ -66,6 +172,10 @@ func main() {
 	}
 
 	cmd.AddCommand(extinctions)
+	cmd.AddCommand(check)
+	cmd.AddCommand(stale)
+	cmd.AddCommand(history)
+	cmd.AddCommand(branches)
 
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
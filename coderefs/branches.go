@@ -0,0 +1,170 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/growthbook/gb-find-code-refs/flags"
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/git"
+	"github.com/growthbook/gb-find-code-refs/internal/helpers"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/internal/validation"
+	"github.com/growthbook/gb-find-code-refs/search"
+)
+
+type BranchesRep = gb.BranchesRep
+
+// ScanBranches scans each remote branch of the configured repository that matches cfg.BranchGlob and has commits
+// since cfg.BranchMaxAge, and calls fn with the result of each. Branches are read from their local copies in the
+// object database, so they must have been fetched, but nothing is checked out.
+//
+// Branches no longer on the remote are returned as deleted: local copies of branches deleted from the remote, and
+// branches of known, which lists the branches found on the remote by an earlier run. Since an empty listing cannot be
+// told apart from a failure to list the remote, it is returned as an error rather than reporting every branch deleted.
+func ScanBranches(ctx context.Context, cfg Config, known []string, fn func(Result) error) (BranchesRep, error) {
+	if cfg.Revision != "" {
+		return BranchesRep{}, &ConfigError{Err: errors.New(`the branches command requires a git repository and may not be used with the "revision" option`)}
+	}
+	conflicting := []struct {
+		name  string
+		value string
+	}{
+		{"branch", cfg.Branch},
+		{"ref", cfg.Ref},
+		{"baseRef", cfg.BaseRef},
+		{"outFile", cfg.OutFile},
+	}
+	for _, option := range conflicting {
+		if option.value != "" {
+			return BranchesRep{}, &ConfigError{Err: fmt.Errorf("the %q option may not be used with the branches command", option.name)}
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return BranchesRep{}, &ConfigError{Err: err}
+	}
+	absPath, err := validation.NormalizeAndValidatePath(cfg.Dir)
+	if err != nil {
+		return BranchesRep{}, &ConfigError{Err: fmt.Errorf("could not validate directory option: %w", err)}
+	}
+	maxAge, err := cfg.BranchMaxAgeTime()
+	if err != nil {
+		return BranchesRep{}, &ConfigError{Err: err}
+	}
+
+	repoClient, err := git.NewRepoClient(absPath)
+	if err != nil {
+		return BranchesRep{}, &GitError{Err: err}
+	}
+	remote, err := repoClient.RemoteBranches()
+	if err != nil {
+		return BranchesRep{}, &GitError{Err: fmt.Errorf("unable to list remote branches: %w", err)}
+	}
+	if len(remote) == 0 {
+		// every known branch would be reported as deleted
+		return BranchesRep{}, &GitError{Err: errors.New("no branches were found on the remotes of the repository")}
+	}
+	tracked, err := repoClient.TrackedBranches()
+	if err != nil {
+		return BranchesRep{}, &GitError{Err: fmt.Errorf("unable to list local copies of remote branches: %w", err)}
+	}
+
+	flagsByProject, err := flags.GetFlagsByProject(ctx, cfg)
+	if err != nil {
+		return BranchesRep{}, flagsError(err)
+	}
+	// aliases read from files are generated from the commit of each branch, others only once
+	buildMatcher := func(files fs.FS) (search.Matcher, error) {
+		return search.NewMultiProjectMatcher(ctx, cfg, absPath, files, flagsByProject)
+	}
+	if !search.ReadsAliasFiles(cfg) {
+		matcher, err := buildMatcher(nil)
+		if err != nil {
+			return BranchesRep{}, flagsError(err)
+		}
+		buildMatcher = func(fs.FS) (search.Matcher, error) { return matcher, nil }
+	}
+
+	ret := BranchesRep{
+		RepoName: cfg.RepoName,
+		SyncTime: helpers.MakeTimestamp(),
+		Remote:   make([]string, 0, len(remote)),
+		Scanned:  []string{},
+		Deleted:  []string{},
+	}
+	for name := range remote {
+		ret.Remote = append(ret.Remote, name)
+	}
+	sort.Strings(ret.Remote)
+
+	deleted := map[string]bool{}
+	for _, name := range known {
+		deleted[name] = !remote[name]
+	}
+	fetched := map[string]bool{}
+	for _, branch := range tracked {
+		fetched[branch.Name] = true
+		if !remote[branch.Name] {
+			deleted[branch.Name] = true
+			continue
+		}
+		if len(cfg.BranchGlob) > 0 && !matchesAnyGlob(cfg.BranchGlob, branch.Name) {
+			log.Debug.Printf("skipping branch %s: it does not match %q", branch.Name, cfg.BranchGlob)
+			continue
+		}
+		if !maxAge.IsZero() && branch.Time < maxAge.UnixMilli() {
+			log.Debug.Printf("skipping branch %s: it has no commits since %s", branch.Name, maxAge.Format("2006-01-02"))
+			continue
+		}
+
+		log.Info.Printf("scanning branch %s from %s", branch.Name, branch.Ref)
+		result, err := scanBranch(ctx, cfg, absPath, branch, buildMatcher)
+		if err != nil {
+			if ctx.Err() != nil {
+				return BranchesRep{}, ctx.Err()
+			}
+			log.Error.Printf("unable to scan branch %s: %s", branch.Name, err)
+			ret.Failed = append(ret.Failed, branch.Name)
+			continue
+		}
+		ret.Scanned = append(ret.Scanned, branch.Name)
+		if err := fn(result); err != nil {
+			return ret, err
+		}
+	}
+
+	for _, name := range ret.Remote {
+		if !fetched[name] {
+			log.Warning.Printf("branch %s exists on the remote but has not been fetched, skipping", name)
+		}
+	}
+	for name, isDeleted := range deleted {
+		if isDeleted {
+			ret.Deleted = append(ret.Deleted, name)
+		}
+	}
+	sort.Strings(ret.Deleted)
+	return ret, nil
+}
+
+// scanBranch scans the local copy of a remote branch with the matcher built from the files of its commit
+func scanBranch(ctx context.Context, cfg Config, absPath string, branch git.Branch, buildMatcher func(fs.FS) (search.Matcher, error)) (Result, error) {
+	gitClient, err := git.NewRefClient(absPath, branch.Ref, branch.Name)
+	if err != nil {
+		return Result{}, &GitError{Err: err}
+	}
+	files, err := gitClient.HeadFS()
+	if err != nil {
+		return Result{}, &GitError{Err: err}
+	}
+	matcher, err := buildMatcher(files)
+	if err != nil {
+		return Result{}, flagsError(err)
+	}
+	cfg.Ref = branch.Ref
+	cfg.Branch = branch.Name
+	return scanWithMatcher(ctx, cfg, absPath, gitClient, matcher)
+}
@@ -0,0 +1,106 @@
+package coderefs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanBranches(t *testing.T) {
+	// the remote has a branch for each of its commits
+	remoteDir := t.TempDir()
+	remote, err := git.PlainInit(remoteDir, false)
+	require.NoError(t, err)
+	wt, err := remote.Worktree()
+	require.NoError(t, err)
+	when := time.Now().Add(-24 * time.Hour)
+	commit := func(branch, content string, when time.Time) {
+		require.NoError(t, os.WriteFile(filepath.Join(remoteDir, "app.js"), []byte(content), 0o600))
+		_, err := wt.Add("app.js")
+		require.NoError(t, err)
+		who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: when}
+		hash, err := wt.Commit(branch, &git.CommitOptions{Author: &who, Committer: &who})
+		require.NoError(t, err)
+		require.NoError(t, remote.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), hash)))
+	}
+	commit("old", "isOn('old-checkout')\n", when.AddDate(-1, 0, 0))
+	commit("feature/a", "isOn('new-checkout')\n", when)
+	commit("master", "isOn('new-checkout')\nisOn('old-checkout')\n", when)
+
+	dir := t.TempDir()
+	_, err = git.PlainClone(dir, false, &git.CloneOptions{URL: remoteDir})
+	require.NoError(t, err)
+	// branches deleted or created on the remote after the last fetch
+	require.NoError(t, remote.Storer.RemoveReference(plumbing.NewBranchReferenceName("feature/a")))
+	commit("unfetched", "", when)
+
+	cfg := testConfig(t, `["new-checkout", "old-checkout"]`)
+	cfg.Dir = dir
+	cfg.Revision = ""
+	cfg.Branch = ""
+	cfg.OutDir = t.TempDir()
+
+	scanned := map[string]Result{}
+	collect := func(result Result) error {
+		scanned[result.Branch.Name] = result
+		return nil
+	}
+	branches, err := ScanBranches(context.Background(), cfg, []string{"master", "gone"}, collect)
+	require.NoError(t, err)
+	require.Equal(t, []string{"master", "old", "unfetched"}, branches.Remote)
+	require.Equal(t, []string{"master", "old"}, branches.Scanned)
+	require.Equal(t, []string{"feature/a", "gone"}, branches.Deleted)
+	require.Empty(t, branches.Failed)
+	require.Len(t, scanned["master"].Branch.References[0].Hunks, 2)
+	require.Equal(t, "old-checkout", scanned["old"].Branch.References[0].Hunks[0].FlagKey)
+
+	scanned = map[string]Result{}
+	cfg.BranchMaxAge = "30d"
+	branches, err = ScanBranches(context.Background(), cfg, nil, collect)
+	require.NoError(t, err)
+	require.Equal(t, []string{"master"}, branches.Scanned)
+	require.Equal(t, []string{"feature/a"}, branches.Deleted)
+
+	cfg.BranchMaxAge = ""
+	cfg.BranchGlob = []string{"o*"}
+	branches, err = ScanBranches(context.Background(), cfg, nil, collect)
+	require.NoError(t, err)
+	require.Equal(t, []string{"old"}, branches.Scanned)
+
+	cfg.Ref = "master"
+	_, err = ScanBranches(context.Background(), cfg, nil, collect)
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr)
+
+	// branches of a bare mirror are its own branches
+	mirrorDir := t.TempDir()
+	_, err = git.PlainClone(mirrorDir, true, &git.CloneOptions{URL: remoteDir, Mirror: true})
+	require.NoError(t, err)
+	cfg.Dir = mirrorDir
+	cfg.Ref = ""
+	cfg.BranchGlob = nil
+	branches, err = ScanBranches(context.Background(), cfg, nil, collect)
+	require.NoError(t, err)
+	require.Equal(t, []string{"master", "old", "unfetched"}, branches.Scanned)
+	require.Equal(t, []string{}, branches.Deleted)
+
+	// a remote without branches is an error rather than deleting every branch
+	head, err := remote.Head()
+	require.NoError(t, err)
+	_, err = remote.CreateTag("v1", head.Hash(), nil)
+	require.NoError(t, err)
+	for _, branch := range []string{"master", "old", "unfetched"} {
+		require.NoError(t, remote.Storer.RemoveReference(plumbing.NewBranchReferenceName(branch)))
+	}
+	cfg.Dir = dir
+	_, err = ScanBranches(context.Background(), cfg, []string{"master", "old"}, collect)
+	var gitErr *GitError
+	require.ErrorAs(t, err, &gitErr)
+}
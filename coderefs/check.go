@@ -0,0 +1,138 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+// Policy rules reported by Check
+const (
+	RuleForbiddenFlag        = "forbidden-flag"
+	RuleDisallowedPath       = "disallowed-path"
+	RuleMaxReferencesPerFlag = "max-references-per-flag"
+	RuleMaxFilesPerFlag      = "max-files-per-flag"
+	RuleMaxFlagsPerFile      = "max-flags-per-file"
+)
+
+// Violation is a code reference that breaks a rule of the configured policy. Rules limiting the number of
+// references are reported at the first reference of the flag or file.
+type Violation struct {
+	Rule    string `json:"rule"`
+	FlagKey string `json:"flagKey,omitempty"`
+	ProjKey string `json:"projKey,omitempty"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s:%d: %s [%s]", v.Path, v.Line, v.Message, v.Rule)
+}
+
+// Check scans the configured directory and returns the code references that break cfg.Policy. History is not
+// searched, and cfg.FlagFilters are not applied, since the policy may forbid the flags they exclude, such as archived
+// flags.
+func Check(ctx context.Context, cfg Config) ([]Violation, error) {
+	if cfg.Policy.IsEmpty() {
+		return nil, &ConfigError{Err: errors.New(`no rules are configured in the "policy" section`)}
+	}
+	cfg.FlagFilters = options.FlagFilters{}
+	cfg.Lookback = 0
+	cfg.Introductions = false
+	cfg.Blame = false
+	result, err := Scan(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluatePolicy(cfg.Policy, result.Branch), nil
+}
+
+// EvaluatePolicy returns the code references of branch that break policy, sorted by path and line
+func EvaluatePolicy(policy options.Policy, branch BranchRep) []Violation {
+	type flagId struct{ projKey, flagKey string }
+
+	violations := []Violation{}
+	refsByFlag := map[flagId][]Violation{}
+	flagOrder := []flagId{}
+	for _, ref := range branch.References {
+		disallowed := matchesAnyGlob(policy.DisallowedPaths, ref.Path)
+		flagsInFile := map[flagId]bool{}
+		var firstInFile *Violation
+		for _, hunk := range ref.Hunks {
+			id := flagId{hunk.ProjKey, hunk.FlagKey}
+			at := Violation{FlagKey: hunk.FlagKey, ProjKey: hunk.ProjKey, Path: ref.Path, Line: hunk.ReferenceLineNumbers()[0]}
+			if firstInFile == nil {
+				firstInFile = &at
+			}
+			flagsInFile[id] = true
+			if _, ok := refsByFlag[id]; !ok {
+				flagOrder = append(flagOrder, id)
+			}
+			refsByFlag[id] = append(refsByFlag[id], at)
+
+			if policy.ForbiddenFlags.Matches(hunk.FlagKey, hunk.FlagProject, hunk.Owner, hunk.Tags, hunk.Archived) {
+				v := at
+				v.Rule = RuleForbiddenFlag
+				v.Message = fmt.Sprintf("flag %q may not be referenced", hunk.FlagKey)
+				violations = append(violations, v)
+			}
+			if disallowed {
+				v := at
+				v.Rule = RuleDisallowedPath
+				v.Message = fmt.Sprintf("flag %q may not be referenced in %s", hunk.FlagKey, ref.Path)
+				violations = append(violations, v)
+			}
+		}
+
+		if policy.MaxFlagsPerFile > 0 && len(flagsInFile) > policy.MaxFlagsPerFile {
+			v := *firstInFile
+			v.Rule = RuleMaxFlagsPerFile
+			v.FlagKey, v.ProjKey = "", ""
+			v.Message = fmt.Sprintf("%d flags are referenced in this file, the maximum is %d", len(flagsInFile), policy.MaxFlagsPerFile)
+			violations = append(violations, v)
+		}
+	}
+
+	for _, id := range flagOrder {
+		refs := refsByFlag[id]
+		if policy.MaxReferencesPerFlag > 0 && len(refs) > policy.MaxReferencesPerFlag {
+			v := refs[0]
+			v.Rule = RuleMaxReferencesPerFlag
+			v.Message = fmt.Sprintf("flag %q has %d references, the maximum is %d", id.flagKey, len(refs), policy.MaxReferencesPerFlag)
+			violations = append(violations, v)
+		}
+		files := map[string]bool{}
+		for _, r := range refs {
+			files[r.Path] = true
+		}
+		if policy.MaxFilesPerFlag > 0 && len(files) > policy.MaxFilesPerFlag {
+			v := refs[0]
+			v.Rule = RuleMaxFilesPerFlag
+			v.Message = fmt.Sprintf("flag %q is referenced in %d files, the maximum is %d", id.flagKey, len(files), policy.MaxFilesPerFlag)
+			violations = append(violations, v)
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Path != violations[j].Path {
+			return violations[i].Path < violations[j].Path
+		}
+		return violations[i].Line < violations[j].Line
+	})
+	return violations
+}
+
+func matchesAnyGlob(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
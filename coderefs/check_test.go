@@ -0,0 +1,121 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+func testPolicyBranch() BranchRep {
+	return BranchRep{
+		Name: "main",
+		References: []ReferenceHunksRep{
+			{
+				Path: "internal/app.go",
+				Hunks: []HunkRep{
+					{FlagKey: "old-flag", StartingLineNumber: 10, Lines: "// context\nisOn(\"old-flag\")\n// context", Archived: true},
+					{FlagKey: "new-flag", StartingLineNumber: 20, Lines: "isOn(\"new-flag\")", Tags: []string{"beta"}},
+				},
+			},
+			{
+				Path: "web/app.js",
+				Hunks: []HunkRep{
+					{FlagKey: "new-flag", StartingLineNumber: 3, Lines: "if (NEW_FLAG) {", Aliases: []string{"NEW_FLAG"}},
+					{FlagKey: "new-flag", StartingLineNumber: 8, Lines: "isOn('new-flag')"},
+				},
+			},
+		},
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	specs := []struct {
+		name     string
+		policy   options.Policy
+		expected []Violation
+	}{
+		{
+			name:     "no violations",
+			policy:   options.Policy{MaxReferencesPerFlag: 3, MaxFilesPerFlag: 2, MaxFlagsPerFile: 2},
+			expected: []Violation{},
+		},
+		{
+			name:   "forbidden flag by key",
+			policy: options.Policy{ForbiddenFlags: options.ForbiddenFlags{Keys: []string{"old-flag"}}},
+			expected: []Violation{
+				{Rule: RuleForbiddenFlag, FlagKey: "old-flag", Path: "internal/app.go", Line: 11, Message: `flag "old-flag" may not be referenced`},
+			},
+		},
+		{
+			name:   "forbidden flag by metadata",
+			policy: options.Policy{ForbiddenFlags: options.ForbiddenFlags{Archived: true, Tags: []string{"beta"}}},
+			expected: []Violation{
+				{Rule: RuleForbiddenFlag, FlagKey: "old-flag", Path: "internal/app.go", Line: 11, Message: `flag "old-flag" may not be referenced`},
+				{Rule: RuleForbiddenFlag, FlagKey: "new-flag", Path: "internal/app.go", Line: 20, Message: `flag "new-flag" may not be referenced`},
+			},
+		},
+		{
+			name:   "disallowed path",
+			policy: options.Policy{DisallowedPaths: []string{"web/**"}},
+			expected: []Violation{
+				{Rule: RuleDisallowedPath, FlagKey: "new-flag", Path: "web/app.js", Line: 3, Message: `flag "new-flag" may not be referenced in web/app.js`},
+				{Rule: RuleDisallowedPath, FlagKey: "new-flag", Path: "web/app.js", Line: 8, Message: `flag "new-flag" may not be referenced in web/app.js`},
+			},
+		},
+		{
+			name:   "max references and files per flag",
+			policy: options.Policy{MaxReferencesPerFlag: 2, MaxFilesPerFlag: 1},
+			expected: []Violation{
+				{Rule: RuleMaxReferencesPerFlag, FlagKey: "new-flag", Path: "internal/app.go", Line: 20, Message: `flag "new-flag" has 3 references, the maximum is 2`},
+				{Rule: RuleMaxFilesPerFlag, FlagKey: "new-flag", Path: "internal/app.go", Line: 20, Message: `flag "new-flag" is referenced in 2 files, the maximum is 1`},
+			},
+		},
+		{
+			name:   "max flags per file",
+			policy: options.Policy{MaxFlagsPerFile: 1},
+			expected: []Violation{
+				{Rule: RuleMaxFlagsPerFile, Path: "internal/app.go", Line: 11, Message: "2 flags are referenced in this file, the maximum is 1"},
+			},
+		},
+	}
+
+	for _, tt := range specs {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, EvaluatePolicy(tt.policy, testPolicyBranch()))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("violations", func(t *testing.T) {
+		cfg := testConfig(t, `["new-checkout", "old-checkout"]`)
+		cfg.Policy.ForbiddenFlags.Keys = []string{"new-checkout"}
+
+		violations, err := Check(context.Background(), cfg)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		require.Equal(t, "app.js:1: flag \"new-checkout\" may not be referenced [forbidden-flag]", violations[0].String())
+	})
+
+	t.Run("archived flags excluded by flag filters", func(t *testing.T) {
+		cfg := testConfig(t, `[{"key": "new-checkout", "archived": true}]`)
+		cfg.FlagFilters.ExcludeArchived = true
+		cfg.Policy.ForbiddenFlags.Archived = true
+
+		violations, err := Check(context.Background(), cfg)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		require.Equal(t, "app.js:1: flag \"new-checkout\" may not be referenced [forbidden-flag]", violations[0].String())
+	})
+
+	t.Run("empty policy", func(t *testing.T) {
+		cfg := testConfig(t, `["new-checkout"]`)
+		_, err := Check(context.Background(), cfg)
+		var configErr *ConfigError
+		require.True(t, errors.As(err, &configErr), err)
+	})
+}
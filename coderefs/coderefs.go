@@ -1,143 +1,403 @@
 package coderefs
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/growthbook/gb-find-code-refs/internal/gb"
-	"github.com/growthbook/gb-find-code-refs/internal/git"
-	"github.com/growthbook/gb-find-code-refs/internal/helpers"
 	"github.com/growthbook/gb-find-code-refs/internal/log"
 	"github.com/growthbook/gb-find-code-refs/internal/validation"
 	"github.com/growthbook/gb-find-code-refs/options"
-	"github.com/growthbook/gb-find-code-refs/search"
 )
 
-func Run(opts options.Options, extinctions bool) {
-	absPath, err := validation.NormalizeAndValidatePath(opts.Dir)
+// Run scans the configured directory and writes the results to the configured output files. If extinctions is set,
+// only extinctions are written.
+func Run(opts options.Options, extinctions bool) error {
+	ctx := context.Background()
+
+	if opts.BaseRef != "" {
+		return runDiff(ctx, opts)
+	}
+
+	result, err := Scan(ctx, opts)
+	if err != nil {
+		if errors.Is(err, ErrNoFlags) {
+			log.Info.Printf("%s, exiting early", err)
+			return nil
+		}
+		return err
+	}
+
+	if extinctions {
+		if opts.Revision == "" && opts.Lookback > 0 {
+			writeExtinctions(opts, result.Branch, result.Extinctions)
+		}
+		return nil
+	}
+
+	if err := generateHunkOutput(opts, result); err != nil {
+		return err
+	}
+
+	if opts.AliasReport {
+		writeAliasReport(opts, result)
+	}
+	if collisions := result.AliasReport.Collisions; opts.FailOnAliasCollision && len(collisions) > 0 {
+		for _, c := range collisions {
+			log.Error.Printf("alias %q is shared by flags %s", c.Alias, strings.Join(c.FlagKeys, ", "))
+		}
+		return fmt.Errorf("found %d aliases shared by several flags", len(collisions))
+	}
+	return nil
+}
+
+// RunCheck scans the configured directory and prints the code references that break the configured policy. An error
+// is returned if any are found.
+func RunCheck(opts options.Options) error {
+	violations, err := Check(context.Background(), opts)
+	if err != nil {
+		if errors.Is(err, ErrNoFlags) {
+			log.Info.Printf("%s, exiting early", err)
+			return nil
+		}
+		return err
+	}
+
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("found %d policy violations", len(violations))
+	}
+	log.Info.Printf("no policy violations found")
+	return nil
+}
+
+// RunStale lists the flags without code references, with the commit that last removed each one, and writes them
+// to a stale JSON file
+func RunStale(opts options.Options) error {
+	stale, err := Stale(context.Background(), opts)
+	if err != nil {
+		if errors.Is(err, ErrNoFlags) {
+			log.Info.Printf("%s, exiting early", err)
+			return nil
+		}
+		return err
+	}
+
+	stale.WriteTable(os.Stdout)
+
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = "."
+	}
+	filename := strings.ReplaceAll(fmt.Sprintf("stale_%s.json", stale.Branch), "/", "_")
+	path := filepath.Join(outDir, filename)
+	data, err := json.Marshal(stale)
+	if err != nil {
+		return fmt.Errorf("unable to marshal stale flags: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("unable to write stale flags: %w", err)
+	}
+	log.Info.Printf("wrote %d stale flags to %s", len(stale.Flags), path)
+	return nil
+}
+
+// RunHistory counts the references to each flag across git history, and writes the time series to a history file
+// for each of the configured json and csv formats. Other formats are skipped, and it is an error if no json or csv
+// format is configured.
+func RunHistory(opts options.Options) error {
+	formats := []string{}
+	for _, format := range opts.Formats {
+		if format == "json" || format == "csv" {
+			formats = append(formats, format)
+		} else {
+			log.Warning.Printf("reference history can not be written as %s", format)
+		}
+	}
+	if len(opts.Formats) == 0 {
+		formats = []string{"json"}
+	}
+	if len(formats) == 0 {
+		return &ConfigError{Err: fmt.Errorf(`reference history can only be written as json or csv, but "format" is %s`, strings.Join(opts.Formats, ", "))}
+	}
+
+	history, err := History(context.Background(), opts)
 	if err != nil {
-		log.Error.Fatalf("could not validate directory option: %s", err)
+		if errors.Is(err, ErrNoFlags) {
+			log.Info.Printf("%s, exiting early", err)
+			return nil
+		}
+		return err
 	}
 
-	log.Info.Printf("absolute directory path: %s", absPath)
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = "."
+	}
 
-	branchName := opts.Branch
-	revision := opts.Revision
-	var gitClient *git.Client
-	var commitTime int64
-	if revision == "" {
-		gitClient, err = git.NewClient(absPath, branchName, opts.AllowTags)
+	for _, format := range formats {
+		filename := strings.ReplaceAll(fmt.Sprintf("history_%s.%s", history.Branch, format), "/", "_")
+		path := filepath.Join(outDir, filename)
+		f, err := os.Create(path)
 		if err != nil {
-			log.Error.Fatalf("%s", err)
+			return fmt.Errorf("unable to create history file: %w", err)
+		}
+		if format == "csv" {
+			err = history.WriteCSV(f)
+		} else {
+			err = history.WriteJSON(f)
 		}
-		branchName = gitClient.GitBranch
-		revision = gitClient.GitSha
-		commitTime = gitClient.GitTimestamp
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("unable to write history file: %w", err)
+		}
+		log.Info.Printf("wrote reference history of %d commits to %s", len(history.Points), path)
 	}
+	return nil
+}
 
-	matcher, refs := search.Scan(opts, absPath)
+// branchesFilename is the name of the file listing the branches scanned by RunBranches, which is also read to
+// find the branches deleted since the previous run
+const branchesFilename = "branches.json"
 
-	branch := gb.BranchRep{
-		Name:       strings.TrimPrefix(branchName, "refs/heads/"),
-		Head:       revision,
-		SyncTime:   helpers.MakeTimestamp(),
-		References: refs,
-		CommitTime: commitTime,
+// RunBranches scans each selected remote branch, writes the code references of each to the configured output
+// files, and writes the list of scanned and deleted branches to a branches file
+func RunBranches(opts options.Options) error {
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = "."
 	}
+	path := filepath.Join(outDir, branchesFilename)
 
-	if !extinctions {
-		generateHunkOutput(opts, matcher, branch)
+	var previous BranchesRep
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &previous); err != nil {
+			log.Warning.Printf("unable to read branches of the previous run from %s: %s", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Warning.Printf("unable to read branches of the previous run from %s: %s", path, err)
 	}
 
-	if gitClient != nil && extinctions {
-		runExtinctions(opts, matcher, branch, gitClient)
+	branches, err := ScanBranches(context.Background(), opts, previous.Remote, func(result Result) error {
+		if err := generateHunkOutput(opts, result); err != nil {
+			return err
+		}
+		if opts.AliasReport {
+			writeAliasReport(opts, result)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNoFlags) {
+			log.Info.Printf("%s, exiting early", err)
+			return nil
+		}
+		return err
+	}
+
+	data, err = json.Marshal(branches)
+	if err != nil {
+		return fmt.Errorf("unable to marshal branches: %w", err)
 	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("unable to write branches: %w", err)
+	}
+	log.Info.Printf("scanned %d of %d remote branches, wrote %d deleted branches to %s", len(branches.Scanned), len(branches.Remote), len(branches.Deleted), path)
+
+	if len(branches.Failed) > 0 {
+		return fmt.Errorf("failed to scan %d branches", len(branches.Failed))
+	}
+	return nil
+}
+
+func writeAliasReport(opts options.Options, result Result) {
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = "."
+	}
+	report := result.AliasReport
+	filename := strings.ReplaceAll(fmt.Sprintf("alias_report_%s.json", result.Branch.Name), "/", "_")
+	path := filepath.Join(outDir, filename)
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		log.Warning.Printf("unable to marshal alias report: %s", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Warning.Printf("unable to write alias report: %s", err)
+		return
+	}
+	if report.Truncated {
+		log.Warning.Printf("the search stopped at the maximum number of code references, so aliases without references are not listed")
+	}
+	log.Info.Printf(
+		"wrote alias report to %s: %d aliases without references, %d aliases shared by several flags, %d aliases contained in common words",
+		path,
+		len(report.Unused),
+		len(report.Collisions),
+		len(report.CommonWords),
+	)
 }
 
-func generateHunkOutput(opts options.Options, matcher search.Matcher, branch gb.BranchRep) {
+func generateHunkOutput(opts options.Options, result Result) error {
 	// default to current directory
 	outDir := opts.OutDir
 	if outDir == "" {
 		outDir = "."
 	}
 
-	outPath, err := branch.WriteToJSON(outDir, opts)
+	branch := result.Branch
+	outPaths, err := result.Report().WriteOutputs(outDir, opts)
 	if err != nil {
-		log.Error.Fatalf("error writing code references to csv: %s", err)
+		return fmt.Errorf("error writing code references: %w", err)
+	}
+	for _, outPath := range outPaths {
+		log.Info.Printf("wrote code references to %s", outPath)
+	}
+
+	if opts.Upload {
+		if err := uploadCodeRefs(opts, result.Report()); err != nil {
+			return err
+		}
 	}
-	log.Info.Printf("wrote code references to %s", outPath)
 
 	if opts.Debug {
 		branch.PrintReferenceCountTable()
+		branch.PrintOwnerTable()
 	}
 
-	totalFlags := 0
-	for _, searchElems := range matcher.Elements {
-		totalFlags += len(searchElems.Elements)
-	}
 	log.Info.Printf(
 		"found %d code references across %d flags and %d files",
 		branch.TotalHunkCount(),
-		totalFlags,
+		result.FlagCount,
 		len(branch.References),
 	)
+	return nil
 }
 
-func runExtinctions(opts options.Options, matcher search.Matcher, branch gb.BranchRep, gitClient *git.Client) {
-	if opts.Lookback > 0 {
-		var removedFlags []gb.ExtinctionRep
+func uploadCodeRefs(opts options.Options, report gb.Report) error {
+	client := gb.NewApiClient(gb.ApiOptions{Host: opts.ApiHost, ApiKey: opts.ApiKey})
+	requests, err := gb.PrepareUpload(report.ToOutputJSON())
+	if err != nil {
+		return fmt.Errorf("error preparing code references upload: %w", err)
+	}
 
-		flagCounts := branch.CountByFlag(matcher.GetElements())
-		missingFlags := []string{}
-		for flag, count := range flagCounts {
-			if count == 0 {
-				missingFlags = append(missingFlags, flag)
+	if opts.DryRun {
+		for i, r := range requests {
+			log.Info.Printf("dry run: would POST %d code references and %d extinctions for branch %q (%d bytes gzipped) to %s (request %d of %d)",
+				len(r.Output.Refs), len(r.Output.Extinctions), r.Output.Branch, len(r.Body), client.CodeRefsURL(), i+1, len(requests))
+			if opts.Debug {
+				body, _ := json.MarshalIndent(r.Output, "", "  ")
+				log.Debug.Printf("dry run: request body:\n%s", body)
 			}
 		}
-		log.Info.Printf("checking if %d flags without references were removed in the last %d commits for project: %s", len(missingFlags), opts.Lookback, "default")
-		removedFlagsByProject, err := gitClient.FindExtinctions(missingFlags, matcher, opts.Lookback+1)
-		if err != nil {
-			log.Warning.Printf("unable to generate flag extinctions: %s", err)
-		} else {
-			log.Info.Printf("found %d removed flags", len(removedFlagsByProject))
-		}
-		removedFlags = append(removedFlags, removedFlagsByProject...)
+		return nil
+	}
 
-		var outDir string
-		if opts.OutDir == "" {
-			outDir = "."
-		} else {
-			outDir = opts.OutDir
-		}
+	if err := client.UploadCodeRefs(context.Background(), requests); err != nil {
+		return fmt.Errorf("error uploading code references to %s: %w", client.CodeRefsURL(), err)
+	}
+	log.Info.Printf("uploaded code references to %s", client.CodeRefsURL())
+	return nil
+}
 
-		absPath, err := validation.NormalizeAndValidatePath(outDir)
-		if err != nil {
-			log.Warning.Printf("unable normalize and validate path: %s", err)
-			return
-		}
+// writeExtinctions writes extinctions to a separate file, for the extinctions command
+func writeExtinctions(opts options.Options, branch gb.BranchRep, removedFlags []gb.ExtinctionRep) {
+	if removedFlags == nil {
+		removedFlags = []gb.ExtinctionRep{}
+	}
 
-		filename := strings.ReplaceAll(fmt.Sprintf("extinctions_%s.json", branch.Name), "/", "_")
-		path := filepath.Join(absPath, filename)
+	var outDir string
+	if opts.OutDir == "" {
+		outDir = "."
+	} else {
+		outDir = opts.OutDir
+	}
 
-		f, err := os.Create(path)
-		if err != nil {
-			log.Warning.Printf("unable to create file: %s", err)
-			return
-		}
+	absPath, err := validation.NormalizeAndValidatePath(outDir)
+	if err != nil {
+		log.Warning.Printf("unable normalize and validate path: %s", err)
+		return
+	}
+
+	filename := strings.ReplaceAll(fmt.Sprintf("extinctions_%s.json", branch.Name), "/", "_")
+	path := filepath.Join(absPath, filename)
 
-		r, err := json.Marshal(removedFlags)
+	f, err := os.Create(path)
+	if err != nil {
+		log.Warning.Printf("unable to create file: %s", err)
+		return
+	}
+	defer f.Close()
+
+	r, err := json.Marshal(removedFlags)
+	if err != nil {
+		log.Warning.Printf("unable to marshal removed flags: %s", err)
+		return
+	}
+
+	_, err = f.Write(r)
+	if err != nil {
+		log.Warning.Printf("unable to write extinctions file: %s", err)
+		return
+	}
+
+	// formats other than json can report extinctions alongside code references
+	reportOpts := opts
+	reportOpts.Formats = []string{}
+	for _, format := range opts.Formats {
+		if format == "sarif" || format == "markdown" {
+			reportOpts.Formats = append(reportOpts.Formats, format)
+		}
+	}
+	if len(reportOpts.Formats) > 0 {
+		reportPaths, err := branch.WriteOutputs(absPath, reportOpts, removedFlags)
 		if err != nil {
-			log.Warning.Printf("unable to marshal removed flags: %s", err)
+			log.Warning.Printf("unable to write extinctions report: %s", err)
 			return
 		}
+		for _, reportPath := range reportPaths {
+			log.Info.Printf("wrote code references and extinctions to %s", reportPath)
+		}
+	}
+}
 
-		_, err = f.Write(r)
-		if err != nil {
-			log.Warning.Printf("unable to write extinctions file: %s", err)
-			return
+func runDiff(ctx context.Context, opts options.Options) error {
+	diff, err := Diff(ctx, opts)
+	if err != nil {
+		if errors.Is(err, ErrNoFlags) {
+			log.Info.Printf("%s, exiting early", err)
+			return nil
 		}
+		return err
 	}
+
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = "."
+	}
+	outPath, err := diff.WriteToJSON(outDir, opts)
+	if err != nil {
+		return fmt.Errorf("error writing code reference changes to json: %w", err)
+	}
+	log.Info.Printf("wrote code reference changes to %s", outPath)
+
+	log.Info.Printf(
+		"found %d added and %d removed code references across %d changed files",
+		len(diff.Added),
+		len(diff.Removed),
+		len(diff.ChangedFiles),
+	)
+	return nil
 }
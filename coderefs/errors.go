@@ -0,0 +1,48 @@
+package coderefs
+
+import (
+	"github.com/growthbook/gb-find-code-refs/flags"
+)
+
+// ErrNoFlags is returned by Scan and Diff when there are no flags to search for
+var ErrNoFlags = flags.ErrNoFlags
+
+// ConfigError is returned when the configuration of a scan is invalid
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration: " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// FlagsError is returned when flags could not be loaded from the configured flags file or API, or their aliases
+// could not be generated
+type FlagsError struct {
+	Err error
+}
+
+func (e *FlagsError) Error() string {
+	return "could not load flags: " + e.Err.Error()
+}
+
+func (e *FlagsError) Unwrap() error {
+	return e.Err
+}
+
+// GitError is returned when the repository metadata or history could not be read
+type GitError struct {
+	Err error
+}
+
+func (e *GitError) Error() string {
+	return "git: " + e.Err.Error()
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
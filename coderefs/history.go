@@ -0,0 +1,50 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/search"
+)
+
+// History returns the number of lines referencing each flag at every cfg.HistoryInterval-th commit between cfg.Since
+// and cfg.Until, or at every commit if cfg.HistoryInterval is 0, oldest first. The checked out commit is searched in full, and older commits are counted from the
+// changes between commits, so the working tree is not scanned.
+func History(ctx context.Context, cfg Config) (HistoryRep, error) {
+	if cfg.Revision != "" {
+		return HistoryRep{}, &ConfigError{Err: errors.New(`reference history is read from git and may not be searched with the "revision" option`)}
+	}
+	history, err := historyOptions(cfg, 0)
+	if err != nil {
+		return HistoryRep{}, &ConfigError{Err: err}
+	}
+	absPath, gitClient, err := prepare(cfg)
+	if err != nil {
+		return HistoryRep{}, err
+	}
+
+	files, err := aliasFiles(cfg, gitClient)
+	if err != nil {
+		return HistoryRep{}, err
+	}
+	matcher, err := search.BuildMatcher(ctx, cfg, absPath, files)
+	if err != nil {
+		return HistoryRep{}, flagsError(err)
+	}
+	points, err := gitClient.FindReferenceHistory(ctx, matcher.GetElementsByProject(), matcher, history, cfg.HistoryInterval)
+	if err != nil {
+		if ctx.Err() != nil {
+			return HistoryRep{}, ctx.Err()
+		}
+		return HistoryRep{}, &GitError{Err: err}
+	}
+	log.Info.Printf("counted references at %d commits", len(points))
+
+	return HistoryRep{
+		Branch: strings.TrimPrefix(gitClient.GitBranch, "refs/heads/"),
+		Head:   gitClient.GitSha,
+		Points: points,
+	}, nil
+}
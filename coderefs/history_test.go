@@ -0,0 +1,18 @@
+package coderefs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHistory_UnsupportedFormats(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout"]`)
+	cfg.Revision = ""
+	cfg.Formats = []string{"sarif", "markdown"}
+
+	err := RunHistory(cfg)
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr)
+	require.Contains(t, err.Error(), "json or csv")
+}
@@ -0,0 +1,329 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/growthbook/gb-find-code-refs/internal/codeowners"
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/git"
+	"github.com/growthbook/gb-find-code-refs/internal/helpers"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/internal/validation"
+	"github.com/growthbook/gb-find-code-refs/options"
+	"github.com/growthbook/gb-find-code-refs/search"
+)
+
+// Config configures a scan. It is the same set of options accepted by the command line, and may be built
+// directly without any viper configuration.
+type Config = options.Options
+
+type (
+	BranchRep          = gb.BranchRep
+	ReferenceHunksRep  = gb.ReferenceHunksRep
+	HunkRep            = gb.HunkRep
+	ExtinctionRep      = gb.ExtinctionRep
+	IntroductionRep    = gb.IntroductionRep
+	DiffRep            = gb.DiffRep
+	ReferenceChangeRep = gb.ReferenceChangeRep
+	Report             = gb.Report
+	AliasReport        = search.AliasReport
+	StaleRep           = gb.StaleRep
+	StaleFlagRep       = gb.StaleFlagRep
+	HistoryRep         = gb.HistoryRep
+	HistoryPointRep    = gb.HistoryPointRep
+	HistoryCountRep    = gb.HistoryCountRep
+)
+
+// Result is the result of scanning a repository
+type Result struct {
+	Branch   BranchRep
+	RepoName string
+
+	// Extinctions are flags without references that had references removed within the configured lookback.
+	// It is nil if history was not searched.
+	Extinctions []ExtinctionRep
+
+	// Introductions are the commits that first added a reference to each flag with references. It is nil unless
+	// the Introductions option is set.
+	Introductions []IntroductionRep
+
+	// FlagCount is the number of flags searched for across all projects
+	FlagCount int
+
+	// AliasReport lists aliases without references, aliases shared by several flags and aliases likely to
+	// produce false positives
+	AliasReport AliasReport
+}
+
+// Report returns the result in the form accepted by output writers
+func (r Result) Report() Report {
+	return Report{Branch: r.Branch, RepoName: r.RepoName, Extinctions: r.Extinctions, Introductions: r.Introductions}
+}
+
+// Scan searches the configured directory for code references, or the commit of Ref if it is set. When the directory
+// is a git repository and Lookback is greater than 0, recent history is also searched for extinct flags. Scan stops when ctx is cancelled.
+func Scan(ctx context.Context, cfg Config) (Result, error) {
+	result, _, _, err := scan(ctx, cfg)
+	return result, err
+}
+
+// scan implements Scan, and also returns the matcher and the git client (if any) for further searches of history
+func scan(ctx context.Context, cfg Config) (Result, search.Matcher, *git.Client, error) {
+	absPath, gitClient, err := prepare(cfg)
+	if err != nil {
+		return Result{}, search.Matcher{}, nil, err
+	}
+
+	files, err := aliasFiles(cfg, gitClient)
+	if err != nil {
+		return Result{}, search.Matcher{}, nil, err
+	}
+	matcher, err := search.BuildMatcher(ctx, cfg, absPath, files)
+	if err != nil {
+		return Result{}, matcher, nil, flagsError(err)
+	}
+	result, err := scanWithMatcher(ctx, cfg, absPath, gitClient, matcher)
+	if err != nil {
+		return Result{}, matcher, nil, err
+	}
+	return result, matcher, gitClient, nil
+}
+
+// scanWithMatcher searches absPath, or the commit of the ref of gitClient, for the code references of matcher
+func scanWithMatcher(ctx context.Context, cfg Config, absPath string, gitClient *git.Client, matcher search.Matcher) (Result, error) {
+	branchName := cfg.Branch
+	revision := cfg.Revision
+	var commitTime int64
+	if gitClient != nil {
+		branchName = gitClient.GitBranch
+		revision = gitClient.GitSha
+		commitTime = gitClient.GitTimestamp
+	}
+
+	var err error
+	var refs []gb.ReferenceHunksRep
+	if cfg.Ref != "" {
+		refs, err = gitClient.SearchHeadForRefs(ctx, matcher)
+	} else {
+		refs, err = search.SearchForRefs(ctx, absPath, matcher)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("error searching for flag key references: %w", err)
+	}
+	if err := assignCodeOwners(cfg, absPath, gitClient, refs); err != nil {
+		log.Warning.Printf("unable to read code owners: %s", err)
+	}
+	if cfg.Blame && gitClient != nil {
+		log.Info.Printf("blaming %d files with references", len(refs))
+		if err := gitClient.BlameReferences(ctx, refs); err != nil {
+			return Result{}, err
+		}
+	}
+
+	result := Result{
+		Branch: gb.BranchRep{
+			Name:       strings.TrimPrefix(branchName, "refs/heads/"),
+			Head:       revision,
+			SyncTime:   helpers.MakeTimestamp(),
+			References: refs,
+			CommitTime: commitTime,
+		},
+		RepoName:    cfg.RepoName,
+		AliasReport: search.BuildAliasReport(matcher, refs),
+	}
+	for _, elementMatcher := range matcher.Elements {
+		result.FlagCount += len(elementMatcher.Elements)
+	}
+
+	// extinctions are searched for in the same run, so that the tree is only scanned once
+	if gitClient != nil && cfg.Lookback > 0 {
+		result.Extinctions, err = findExtinctions(ctx, cfg, matcher, result.Branch, gitClient)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			log.Warning.Printf("unable to generate flag extinctions: %s", err)
+		}
+	}
+
+	if gitClient != nil && cfg.Introductions {
+		result.Introductions, err = findIntroductions(ctx, cfg, matcher, result.Branch, gitClient)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			log.Warning.Printf("unable to generate flag introductions: %s", err)
+		}
+	}
+
+	return result, nil
+}
+
+// Diff returns the code references added and removed between the merge base of cfg.BaseRef and the checked out commit
+func Diff(ctx context.Context, cfg Config) (DiffRep, error) {
+	absPath, gitClient, err := prepare(cfg)
+	if err != nil {
+		return DiffRep{}, err
+	}
+	if cfg.BaseRef == "" {
+		return DiffRep{}, &ConfigError{Err: errors.New(`"baseRef" option is required`)}
+	}
+	if gitClient == nil {
+		return DiffRep{}, &ConfigError{Err: errors.New(`"baseRef" option requires a git repository`)}
+	}
+
+	files, err := aliasFiles(cfg, gitClient)
+	if err != nil {
+		return DiffRep{}, err
+	}
+	matcher, err := search.BuildMatcher(ctx, cfg, absPath, files)
+	if err != nil {
+		return DiffRep{}, flagsError(err)
+	}
+	diff, err := gitClient.FindReferenceChanges(ctx, cfg.BaseRef, matcher)
+	if err != nil {
+		return DiffRep{}, &GitError{Err: fmt.Errorf("error comparing against base ref %q: %w", cfg.BaseRef, err)}
+	}
+	diff.Branch = strings.TrimPrefix(gitClient.GitBranch, "refs/heads/")
+	return diff, nil
+}
+
+// prepare validates cfg and returns the absolute path of the directory to scan, with a git client unless a
+// revision is configured. If a ref is configured, the client reads the commit of the ref instead of the working tree.
+func prepare(cfg Config) (string, *git.Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", nil, &ConfigError{Err: err}
+	}
+	absPath, err := validation.NormalizeAndValidatePath(cfg.Dir)
+	if err != nil {
+		return "", nil, &ConfigError{Err: fmt.Errorf("could not validate directory option: %w", err)}
+	}
+	log.Info.Printf("absolute directory path: %s", absPath)
+
+	if cfg.Revision != "" {
+		return absPath, nil, nil
+	}
+	if cfg.Ref != "" {
+		gitClient, err := git.NewRefClient(absPath, cfg.Ref, cfg.Branch)
+		if err != nil {
+			return "", nil, &GitError{Err: err}
+		}
+		return absPath, gitClient, nil
+	}
+	gitClient, err := git.NewClient(absPath, cfg.Branch, cfg.AllowTags)
+	if err != nil {
+		return "", nil, &GitError{Err: err}
+	}
+	return absPath, gitClient, nil
+}
+
+// aliasFiles returns the files of the commit of the configured ref, from which alias files are read instead of the
+// working tree. It returns nil if no ref is configured.
+func aliasFiles(cfg Config, gitClient *git.Client) (fs.FS, error) {
+	if cfg.Ref == "" || gitClient == nil {
+		return nil, nil
+	}
+	files, err := gitClient.HeadFS()
+	if err != nil {
+		return nil, &GitError{Err: err}
+	}
+	return files, nil
+}
+
+func flagsError(err error) error {
+	if errors.Is(err, ErrNoFlags) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &FlagsError{Err: err}
+}
+
+// findExtinctions searches recent commit history for flags without references that had references removed
+func findExtinctions(ctx context.Context, cfg Config, matcher search.Matcher, branch gb.BranchRep, gitClient *git.Client) ([]gb.ExtinctionRep, error) {
+	missingFlagsByProject := missingFlags(matcher, branch)
+	for projKey, missingFlags := range missingFlagsByProject {
+		log.Info.Printf("checking if %d flags without references were removed in the last %d commits for project: %q", len(missingFlags), cfg.Lookback, projKey)
+	}
+	history, err := historyOptions(cfg, cfg.Lookback)
+	if err != nil {
+		return nil, err
+	}
+	removedFlags, err := gitClient.FindExtinctions(ctx, missingFlagsByProject, matcher, history)
+	if err != nil {
+		return nil, err
+	}
+	log.Info.Printf("found %d removed flags", len(removedFlags))
+	return removedFlags, nil
+}
+
+// findIntroductions searches commit history for the commit that first added a reference to each flag with references
+func findIntroductions(ctx context.Context, cfg Config, matcher search.Matcher, branch gb.BranchRep, gitClient *git.Client) ([]gb.IntroductionRep, error) {
+	history, err := historyOptions(cfg, 0)
+	if err != nil {
+		return nil, err
+	}
+	flagCounts := branch.CountByProjectAndFlag(matcher.GetElementsByProject())
+	referencedFlagsByProject := make(map[string][]string, len(flagCounts))
+	for projKey, projectFlagCounts := range flagCounts {
+		for flag, count := range projectFlagCounts {
+			if count > 0 {
+				referencedFlagsByProject[projKey] = append(referencedFlagsByProject[projKey], flag)
+			}
+		}
+		log.Info.Printf("searching history for the introduction of %d flags with references for project: %q", len(referencedFlagsByProject[projKey]), projKey)
+	}
+	introductions, err := gitClient.FindIntroductions(ctx, referencedFlagsByProject, matcher, history)
+	if err != nil {
+		return nil, err
+	}
+	log.Info.Printf("found the introduction of %d flags", len(introductions))
+	return introductions, nil
+}
+
+// missingFlags returns the flags of each project without references on branch
+func missingFlags(matcher search.Matcher, branch gb.BranchRep) map[string][]string {
+	flagCounts := branch.CountByProjectAndFlag(matcher.GetElementsByProject())
+	missingFlagsByProject := make(map[string][]string, len(flagCounts))
+	for projKey, projectFlagCounts := range flagCounts {
+		missingFlags := []string{}
+		for flag, count := range projectFlagCounts {
+			if count == 0 {
+				missingFlags = append(missingFlags, flag)
+			}
+		}
+		missingFlagsByProject[projKey] = missingFlags
+	}
+	return missingFlagsByProject
+}
+
+// historyOptions returns the configured bounds of history searches, reading at most lookback commits
+func historyOptions(cfg Config, lookback int) (git.HistoryOptions, error) {
+	since, until, err := cfg.HistoryWindow()
+	if err != nil {
+		return git.HistoryOptions{}, err
+	}
+	return git.HistoryOptions{Lookback: lookback, Since: since, Until: until, FirstParent: cfg.FirstParent}, nil
+}
+
+// assignCodeOwners sets the owners of each file with references from the CODEOWNERS file of the repository, if any.
+// In ref mode the CODEOWNERS file is read from the scanned commit.
+func assignCodeOwners(cfg Config, dir string, gitClient *git.Client, refs []gb.ReferenceHunksRep) error {
+	var ruleset *codeowners.Ruleset
+	var err error
+	if cfg.Ref != "" {
+		ruleset, err = codeowners.Find(gitClient.OpenHeadFile)
+	} else {
+		ruleset, err = codeowners.Load(dir)
+	}
+	if err != nil || ruleset == nil {
+		return err
+	}
+	log.Info.Printf("assigning code owners from %s", ruleset.Path)
+	for i := range refs {
+		refs[i].Owners = ruleset.Owners(refs[i].Path)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+func testConfig(t *testing.T, flagsJSON string) Config {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("if (isOn('new-checkout')) {\n  render()\n}\n"), 0o600))
+	flagsPath := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(flagsPath, []byte(flagsJSON), 0o600))
+	return Config{
+		Dir:       dir,
+		FlagsPath: flagsPath,
+		Branch:    "main",
+		Revision:  "abc123",
+		RepoName:  "repo",
+	}
+}
+
+// log.Init is not called by the tests of this package, as library callers cannot call it
+func TestScan(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout", "old-checkout"]`)
+
+	result, err := Scan(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "main", result.Branch.Name)
+	require.Equal(t, "abc123", result.Branch.Head)
+	require.Equal(t, "repo", result.RepoName)
+	require.Equal(t, 2, result.FlagCount)
+	require.Nil(t, result.Extinctions)
+	require.Len(t, result.Branch.References, 1)
+	require.Equal(t, "app.js", result.Branch.References[0].Path)
+	require.Equal(t, "new-checkout", result.Branch.References[0].Hunks[0].FlagKey)
+}
+
+func TestScan_CodeOwners(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout"]`)
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.Dir, ".github"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, ".github", "CODEOWNERS"), []byte("* @org/everyone\n*.js @org/frontend @jane\n"), 0o600))
+
+	result, err := Scan(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, result.Branch.References, 1)
+	require.Equal(t, []string{"@org/frontend", "@jane"}, result.Branch.References[0].Owners)
+	require.Equal(t, []string{"@org/frontend", "@jane"}, result.Branch.Records()[0].CodeOwners)
+}
+
+func TestScan_Errors(t *testing.T) {
+	t.Run("invalid config", func(t *testing.T) {
+		cfg := testConfig(t, `["new-checkout"]`)
+		cfg.ContextLines = 10
+		_, err := Scan(context.Background(), cfg)
+		var configErr *ConfigError
+		require.True(t, errors.As(err, &configErr), err)
+	})
+
+	t.Run("invalid flags file", func(t *testing.T) {
+		cfg := testConfig(t, `not json`)
+		_, err := Scan(context.Background(), cfg)
+		var flagsErr *FlagsError
+		require.True(t, errors.As(err, &flagsErr), err)
+	})
+
+	t.Run("no flags", func(t *testing.T) {
+		cfg := testConfig(t, `["ab"]`)
+		_, err := Scan(context.Background(), cfg)
+		require.ErrorIs(t, err, ErrNoFlags)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cfg := testConfig(t, `["new-checkout"]`)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Scan(ctx, cfg)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestScan_Lookback(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout", "old-checkout"]`)
+	cfg.Revision = ""
+	cfg.Branch = ""
+
+	repo, err := git.PlainInit(cfg.Dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	commit := func(message, path, content string) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, path), []byte(content), 0o600))
+		_, err := wt.Add(path)
+		require.NoError(t, err)
+		who.When = who.When.Add(time.Minute)
+		hash, err := wt.Commit(message, &git.CommitOptions{Author: &who, Committer: &who})
+		require.NoError(t, err)
+		return hash
+	}
+	commit("add checkout", "app.js", "isOn('new-checkout')\nisOn('old-checkout')\n")
+	removal := commit("remove old checkout", "app.js", "isOn('new-checkout')\n")
+	commit("unrelated change", "README", "readme")
+
+	// the removal is the second commit, so it is outside a lookback of 1
+	cfg.Lookback = 1
+	result, err := Scan(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Empty(t, result.Extinctions)
+
+	cfg.Lookback = 2
+	result, err = Scan(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, result.Extinctions, 1)
+	require.Equal(t, removal.String(), result.Extinctions[0].Revision)
+	require.Equal(t, "old-checkout", result.Extinctions[0].FlagKey)
+}
+
+func TestScan_Ref(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout", "old-checkout"]`)
+	cfg.Revision = ""
+	cfg.Branch = ""
+	cfg.Ref = "feature"
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, "CODEOWNERS"), []byte("*.js @org/frontend\n"), 0o600))
+
+	repo, err := git.PlainInit(cfg.Dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.AddGlob("*"))
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	head, err := wt.Commit("add checkout", &git.CommitOptions{Author: &who, Committer: &who})
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", head)))
+	// the working tree is not read
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, "app.js"), []byte("isOn('old-checkout')\n"), 0o600))
+
+	result, err := Scan(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, "feature", result.Branch.Name)
+	require.Equal(t, head.String(), result.Branch.Head)
+	require.Equal(t, int64(100000000000), result.Branch.CommitTime)
+	require.Len(t, result.Branch.References, 1)
+	require.Equal(t, "new-checkout", result.Branch.References[0].Hunks[0].FlagKey)
+	require.Equal(t, []string{"@org/frontend"}, result.Branch.References[0].Owners)
+
+	cfg.Revision = "abc123"
+	_, err = Scan(context.Background(), cfg)
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr)
+}
+
+func TestScan_RefAliasFiles(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout"]`)
+	cfg.Revision = ""
+	cfg.Branch = ""
+	cfg.Ref = "feature"
+	cfg.Aliases = []options.Alias{{Type: options.Structured, Paths: []string{"flags.json"}, Query: ".features.* == FLAG_KEY"}}
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, "flags.json"), []byte(`{"features": {"CHECKOUT": "new-checkout"}}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, "cart.js"), []byte("if (isOn(CHECKOUT)) {}\n"), 0o600))
+
+	repo, err := git.PlainInit(cfg.Dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.AddGlob("*"))
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	head, err := wt.Commit("add checkout", &git.CommitOptions{Author: &who, Committer: &who})
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", head)))
+	// aliases are read from the commit of the ref rather than the working tree
+	require.NoError(t, os.Remove(filepath.Join(cfg.Dir, "flags.json")))
+
+	result, err := Scan(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, result.Branch.References, 3)
+	require.Equal(t, "cart.js", result.Branch.References[2].Path)
+	require.Equal(t, []string{"CHECKOUT"}, result.Branch.References[2].Hunks[0].Aliases)
+}
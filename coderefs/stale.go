@@ -0,0 +1,72 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+)
+
+// Stale scans the configured directory and returns every flag without code references. For each one, up to
+// cfg.StaleLookback commits between cfg.Since and cfg.Until are searched for the commit that last removed a
+// reference to it.
+func Stale(ctx context.Context, cfg Config) (StaleRep, error) {
+	if cfg.Revision != "" {
+		return StaleRep{}, &ConfigError{Err: errors.New(`stale flags are found in git history and may not be searched with the "revision" option`)}
+	}
+	history, err := historyOptions(cfg, cfg.StaleLookback)
+	if err != nil {
+		return StaleRep{}, &ConfigError{Err: err}
+	}
+
+	cfg.Lookback = 0
+	cfg.Introductions = false
+	cfg.Blame = false
+	result, matcher, gitClient, err := scan(ctx, cfg)
+	if err != nil {
+		return StaleRep{}, err
+	}
+
+	missingFlagsByProject := missingFlags(matcher, result.Branch)
+	removals := []ExtinctionRep{}
+	if cfg.StaleLookback > 0 {
+		log.Info.Printf("searching the last %d commits for the removal of flags without references", cfg.StaleLookback)
+		removals, err = gitClient.FindExtinctions(ctx, missingFlagsByProject, matcher, history)
+		if err != nil {
+			if ctx.Err() != nil {
+				return StaleRep{}, ctx.Err()
+			}
+			return StaleRep{}, &GitError{Err: err}
+		}
+	}
+
+	removalsByFlag := make(map[[2]string]ExtinctionRep, len(removals))
+	for _, r := range removals {
+		removalsByFlag[[2]string{r.ProjKey, r.FlagKey}] = r
+	}
+
+	ret := StaleRep{Branch: result.Branch.Name, Head: result.Branch.Head, Flags: []StaleFlagRep{}}
+	for projKey, flags := range missingFlagsByProject {
+		for _, flagKey := range flags {
+			stale := StaleFlagRep{FlagKey: flagKey, ProjKey: projKey}
+			if r, ok := removalsByFlag[[2]string{projKey, flagKey}]; ok {
+				stale.Revision = r.Revision
+				stale.Message = strings.TrimSpace(r.Message)
+				stale.Author = r.Author
+				stale.Time = r.Time
+			}
+			ret.Flags = append(ret.Flags, stale)
+		}
+	}
+	sort.Slice(ret.Flags, func(i, j int) bool {
+		a, b := ret.Flags[i], ret.Flags[j]
+		if a.ProjKey != b.ProjKey {
+			return a.ProjKey < b.ProjKey
+		}
+		return a.FlagKey < b.FlagKey
+	})
+	log.Info.Printf("found %d flags without references, %d removed within the searched history", len(ret.Flags), len(removals))
+	return ret, nil
+}
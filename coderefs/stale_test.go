@@ -0,0 +1,66 @@
+package coderefs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStale(t *testing.T) {
+	cfg := testConfig(t, `["new-checkout", "old-checkout", "unused-flag"]`)
+	cfg.Revision = ""
+	cfg.Branch = ""
+	cfg.StaleLookback = 10
+
+	repo, err := git.PlainInit(cfg.Dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Unix(1700000000, 0)}
+
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, "old.js"), []byte("isOn('old-checkout')\n"), 0o600))
+	_, err = wt.Add(".")
+	require.NoError(t, err)
+	_, err = wt.Commit("add flags", &git.CommitOptions{Author: &who, Committer: &who})
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(cfg.Dir, "old.js")))
+	who.When = who.When.Add(time.Hour)
+	removal, err := wt.Commit("remove old checkout\n", &git.CommitOptions{All: true, Author: &who, Committer: &who})
+	require.NoError(t, err)
+
+	stale, err := Stale(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, StaleRep{
+		Branch: "master",
+		Head:   removal.String(),
+		Flags: []StaleFlagRep{
+			{FlagKey: "old-checkout", Revision: removal.String(), Message: "remove old checkout", Author: "Jane", Time: who.When.UnixMilli()},
+			{FlagKey: "unused-flag"},
+		},
+	}, stale)
+
+	t.Run("since", func(t *testing.T) {
+		cfg := cfg
+		cfg.Since = who.When.Add(time.Minute).Format(time.RFC3339)
+		stale, err := Stale(context.Background(), cfg)
+		require.NoError(t, err)
+		require.Equal(t, []StaleFlagRep{{FlagKey: "old-checkout"}, {FlagKey: "unused-flag"}}, stale.Flags)
+	})
+
+	t.Run("revision", func(t *testing.T) {
+		cfg := cfg
+		cfg.Branch = "main"
+		cfg.Revision = "abc123"
+		_, err := Stale(context.Background(), cfg)
+		var configErr *ConfigError
+		require.True(t, errors.As(err, &configErr), err)
+	})
+}
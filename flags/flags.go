@@ -1,10 +1,17 @@
 package flags
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/helpers"
 	"github.com/growthbook/gb-find-code-refs/internal/log"
 	"github.com/growthbook/gb-find-code-refs/options"
 )
@@ -13,23 +20,164 @@ const (
 	minFlagKeyLen = 3 // Minimum flag key length helps reduce the number of false positives
 )
 
-func GetFlagKeys(opts options.Options) []string {
-	flags, err := getFlags(opts.FlagsPath)
+// ErrNoFlags is returned when no flags are left to search for after filtering
+var ErrNoFlags = fmt.Errorf("no flag keys longer than the minimum flag key length (%d) were found", minFlagKeyLen)
+
+// FlagSource provides the list of flags to search for
+type FlagSource interface {
+	GetFlags(ctx context.Context) ([]gb.FlagRep, error)
+}
+
+// NewFlagSource returns the flag source configured by opts. A local flags file takes precedence over the GrowthBook API.
+func NewFlagSource(opts options.Options) (FlagSource, error) {
+	if opts.FlagsPath != "" {
+		return fileSource{path: opts.FlagsPath}, nil
+	}
+	if opts.ApiKey != "" {
+		return apiSource{client: gb.NewApiClient(gb.ApiOptions{Host: opts.ApiHost, ApiKey: opts.ApiKey})}, nil
+	}
+	return nil, fmt.Errorf("one of %q or %q must be set", "flagsPath", "apiKey")
+}
+
+// GetFlagsByProject returns the flags to search for in each configured project, after applying the configured
+// flag filters. When no projects are configured, all flags are returned under an empty project key.
+// ErrNoFlags is returned if no flags are left to search for.
+func GetFlagsByProject(ctx context.Context, opts options.Options) (map[string][]gb.FlagRep, error) {
+	var sourceFlags []gb.FlagRep
+	getSourceFlags := func() ([]gb.FlagRep, error) {
+		if sourceFlags == nil {
+			source, err := NewFlagSource(opts)
+			if err != nil {
+				return nil, err
+			}
+			sourceFlags, err = loadFlags(ctx, source)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return sourceFlags, nil
+	}
+
+	flagsByProject := make(map[string][]gb.FlagRep, len(opts.Projects)+1)
+	if len(opts.Projects) == 0 {
+		flags, err := getSourceFlags()
+		if err != nil {
+			return nil, err
+		}
+		flagsByProject[""] = filterProjectFlags("", flags, opts.FlagFilters)
+	}
+	for _, project := range opts.Projects {
+		var projectFlags []gb.FlagRep
+		if project.FlagsPath != "" {
+			flagsPath := project.FlagsPath
+			if !filepath.IsAbs(flagsPath) {
+				flagsPath = filepath.Join(opts.Dir, flagsPath)
+			}
+			flags, err := loadFlags(ctx, fileSource{path: flagsPath})
+			if err != nil {
+				return nil, fmt.Errorf("project %q: %w", project.Key, err)
+			}
+			projectFlags = flags
+		} else {
+			flags, err := getSourceFlags()
+			if err != nil {
+				return nil, err
+			}
+			if !hasProjectMetadata(flags) {
+				// flags can't be assigned to projects, e.g. plain flag keys or an SDK payload
+				log.Info.Printf("top level flags have no project metadata, searching all of them for project: %q", project.Key)
+				projectFlags = flags
+			} else {
+				assigned := 0
+				for _, flag := range flags {
+					// flags without a project apply to every project
+					if flag.Project == "" || flag.Project == project.Key {
+						projectFlags = append(projectFlags, flag)
+					}
+					if flag.Project == project.Key {
+						assigned++
+					}
+				}
+				if assigned == 0 {
+					log.Warning.Printf("no top level flags have project %q; set \"flagsPath\" for the project or add project metadata to the flags", project.Key)
+				}
+			}
+		}
+		flagsByProject[project.Key] = filterProjectFlags(project.Key, projectFlags, opts.FlagFilters)
+	}
+
+	totalFlags := 0
+	for _, projectFlags := range flagsByProject {
+		totalFlags += len(projectFlags)
+	}
+	if totalFlags == 0 {
+		return nil, ErrNoFlags
+	}
+
+	return flagsByProject, nil
+}
+
+// hasProjectMetadata reports whether any flag is assigned to a project
+func hasProjectMetadata(flags []gb.FlagRep) bool {
+	for _, flag := range flags {
+		if flag.Project != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func loadFlags(ctx context.Context, source FlagSource) ([]gb.FlagRep, error) {
+	flags, err := source.GetFlags(ctx)
 	if err != nil {
-		log.Error.Fatal(fmt.Errorf("could not parse flag keys: %w", err))
+		return nil, fmt.Errorf("could not parse flag keys: %w", err)
 	}
+	return flags, nil
+}
 
-	filteredFlags, omittedFlags := filterShortFlagKeys(flags)
-	if len(filteredFlags) == 0 {
-		log.Info.Printf("no flag keys longer than the minimum flag key length (%v) were found, exiting early",
-			minFlagKeyLen)
-		os.Exit(0)
-	} else if len(omittedFlags) > 0 {
-		log.Warning.Printf("omitting %d flags with keys less than minimum (%d)", len(omittedFlags), minFlagKeyLen)
+func filterProjectFlags(projKey string, flags []gb.FlagRep, filters options.FlagFilters) []gb.FlagRep {
+	flags, excludedFlags := filterFlags(flags, filters)
+	if len(excludedFlags) > 0 {
+		log.Info.Printf("excluding %d flags that do not match the configured flag filters for project: %q", len(excludedFlags), projKey)
+	}
+
+	_, omittedFlags := filterShortFlagKeys(Keys(flags))
+	if len(omittedFlags) > 0 {
+		log.Warning.Printf("omitting %d flags with keys less than minimum (%d) for project: %q", len(omittedFlags), minFlagKeyLen, projKey)
+	}
+
+	filteredFlags := make([]gb.FlagRep, 0, len(flags))
+	for _, flag := range flags {
+		if len(flag.Key) >= minFlagKeyLen {
+			filteredFlags = append(filteredFlags, flag)
+		}
 	}
 	return filteredFlags
 }
 
+// Keys returns the keys of flags
+func Keys(flags []gb.FlagRep) []string {
+	keys := make([]string, 0, len(flags))
+	for _, flag := range flags {
+		keys = append(keys, flag.Key)
+	}
+	return keys
+}
+
+// filterFlags applies the configured flag metadata filters
+func filterFlags(flags []gb.FlagRep, filters options.FlagFilters) (filtered []gb.FlagRep, excluded []gb.FlagRep) {
+	filtered = make([]gb.FlagRep, 0, len(flags))
+	excluded = []gb.FlagRep{}
+	for _, flag := range flags {
+		if filters.Matches(flag.Project, flag.Owner, flag.Tags, flag.Archived) {
+			filtered = append(filtered, flag)
+		} else {
+			excluded = append(excluded, flag)
+		}
+	}
+	return filtered, excluded
+}
+
 // Very short flag keys lead to many false positives when searching in code,
 // so we filter them out.
 func filterShortFlagKeys(flags []string) (filtered []string, omitted []string) {
@@ -45,19 +193,145 @@ func filterShortFlagKeys(flags []string) (filtered []string, omitted []string) {
 	return filteredFlags, omittedFlags
 }
 
-func getFlags(flagsPath string) ([]string, error) {
-	jsonFile, err := os.Open(flagsPath)
+// fileSource reads flags from a local JSON file: a list of flag keys or flag objects, or a GrowthBook SDK payload
+type fileSource struct {
+	path string
+}
+
+func (s fileSource) GetFlags(ctx context.Context) ([]gb.FlagRep, error) {
+	return getFlags(s.path)
+}
+
+// apiSource fetches flags from the GrowthBook REST API
+type apiSource struct {
+	client gb.ApiClient
+}
+
+func (s apiSource) GetFlags(ctx context.Context) ([]gb.FlagRep, error) {
+	return s.client.GetFlags(ctx)
+}
+
+func getFlags(flagsPath string) ([]gb.FlagRep, error) {
+	data, err := os.ReadFile(flagsPath)
 	if err != nil {
 		return nil, err
 	}
-	defer jsonFile.Close()
 
-	var flags []string
+	switch firstToken(data) {
+	case '[':
+		return getFlagsFromList(data)
+	case '{':
+		keys, err := getFlagsFromSDKPayload(data)
+		if err != nil {
+			return nil, err
+		}
+		flags := make([]gb.FlagRep, 0, len(keys))
+		for _, key := range keys {
+			flags = append(flags, gb.FlagRep{Key: key})
+		}
+		return flags, nil
+	}
+
+	return nil, errors.New("expected a JSON array of flags or a GrowthBook SDK payload")
+}
 
-	err = json.NewDecoder(jsonFile).Decode(&flags)
-	if err != nil {
+// getFlagsFromList decodes a list whose items are either flag keys or flag objects, e.g. {"key": "my-flag", "owner": "jane"}
+func getFlagsFromList(data []byte) ([]gb.FlagRep, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
 		return nil, err
 	}
 
+	flags := make([]gb.FlagRep, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for i, item := range items {
+		var flag gb.FlagRep
+		switch firstToken(item) {
+		case '"':
+			if err := json.Unmarshal(item, &flag.Key); err != nil {
+				return nil, err
+			}
+		case '{':
+			if err := json.Unmarshal(item, &flag); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("item %d: expected a flag key or flag object", i)
+		}
+		if flag.Key == "" {
+			return nil, fmt.Errorf("item %d: missing flag key", i)
+		}
+		if _, ok := seen[flag.Key]; ok {
+			continue
+		}
+		seen[flag.Key] = struct{}{}
+		flags = append(flags, flag)
+	}
+
 	return flags, nil
 }
+
+// sdkPayload is the document served by GrowthBook SDK connection endpoints
+type sdkPayload struct {
+	Features map[string]sdkFeature `json:"features"`
+
+	// Encrypted payloads can't be decrypted without the SDK connection key, so they are detected and skipped
+	EncryptedFeatures    string `json:"encryptedFeatures,omitempty"`
+	EncryptedExperiments string `json:"encryptedExperiments,omitempty"`
+}
+
+type sdkFeature struct {
+	Rules []sdkFeatureRule `json:"rules,omitempty"`
+}
+
+type sdkFeatureRule struct {
+	Key        string            `json:"key,omitempty"`
+	Variations []json.RawMessage `json:"variations,omitempty"`
+}
+
+// getFlagsFromSDKPayload returns the feature keys of an SDK payload, along with experiment keys of any inline experiment rules
+func getFlagsFromSDKPayload(data []byte) ([]string, error) {
+	var payload sdkPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.EncryptedFeatures != "" {
+		log.Warning.Printf("skipping encrypted features in SDK payload, only unencrypted features will be scanned")
+	}
+	if payload.EncryptedExperiments != "" {
+		log.Warning.Printf("skipping encrypted experiments in SDK payload")
+	}
+	if payload.Features == nil && payload.EncryptedFeatures == "" {
+		return nil, errors.New(`SDK payload is missing "features"`)
+	}
+
+	featureKeys := make([]string, 0, len(payload.Features))
+	for key := range payload.Features {
+		featureKeys = append(featureKeys, key)
+	}
+	sort.Strings(featureKeys)
+
+	flags := make([]string, 0, len(featureKeys))
+	experimentKeys := []string{}
+	for _, key := range featureKeys {
+		flags = append(flags, key)
+		for _, rule := range payload.Features[key].Rules {
+			// Experiment rules without a key use the feature key as the tracking key
+			if len(rule.Variations) > 0 && rule.Key != "" {
+				experimentKeys = append(experimentKeys, rule.Key)
+			}
+		}
+	}
+
+	return helpers.Dedupe(append(flags, experimentKeys...)), nil
+}
+
+// firstToken returns the first non-whitespace byte of a JSON document
+func firstToken(data []byte) byte {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
@@ -1,12 +1,16 @@
 package flags
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
 	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/options"
 )
 
 func init() {
@@ -53,3 +57,144 @@ func Test_filterShortFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFlagSource(t *testing.T) {
+	flagsPath := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(flagsPath, []byte(`["flag-one", "flag-two"]`), 0600))
+
+	t.Run("file source takes precedence", func(t *testing.T) {
+		source, err := NewFlagSource(options.Options{FlagsPath: flagsPath, ApiKey: "secret"})
+		require.NoError(t, err)
+		require.IsType(t, fileSource{}, source)
+		flags, err := source.GetFlags(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []gb.FlagRep{{Key: "flag-one"}, {Key: "flag-two"}}, flags)
+	})
+
+	t.Run("api source", func(t *testing.T) {
+		source, err := NewFlagSource(options.Options{ApiKey: "secret"})
+		require.NoError(t, err)
+		require.IsType(t, apiSource{}, source)
+	})
+
+	t.Run("no source", func(t *testing.T) {
+		_, err := NewFlagSource(options.Options{})
+		require.Error(t, err)
+	})
+}
+
+func Test_getFlags(t *testing.T) {
+	flagsPath := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(flagsPath, []byte(` ["flag-one"]`), 0600))
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []gb.FlagRep
+		wantErr bool
+	}{
+		{
+			name: "list of flag keys",
+			path: flagsPath,
+			want: []gb.FlagRep{{Key: "flag-one"}},
+		},
+		{
+			name: "list of flag objects",
+			path: "testdata/flags_with_metadata.json",
+			want: []gb.FlagRep{
+				{Key: "new-checkout", Project: "checkout", Owner: "jane", Tags: []string{"frontend"}},
+				{Key: "old-checkout", Project: "checkout", Owner: "joe", Archived: true},
+				{Key: "plain-key"},
+			},
+		},
+		{
+			name: "SDK payload with inline experiments",
+			path: "testdata/sdk_payload.json",
+			want: []gb.FlagRep{{Key: "dark-mode"}, {Key: "new-checkout"}, {Key: "checkout-button-color"}},
+		},
+		{
+			name: "encrypted SDK payload",
+			path: "testdata/sdk_payload_encrypted.json",
+			want: []gb.FlagRep{},
+		},
+		{
+			name:    "missing file",
+			path:    "testdata/missing.json",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getFlags(tt.path)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_filterFlags(t *testing.T) {
+	flags := []gb.FlagRep{
+		{Key: "new-checkout", Project: "checkout", Owner: "jane", Tags: []string{"frontend"}},
+		{Key: "old-checkout", Project: "checkout", Owner: "joe", Archived: true},
+		{Key: "search-ranking", Project: "search", Tags: []string{"backend"}},
+	}
+
+	tests := []struct {
+		name    string
+		filters options.FlagFilters
+		want    []string
+	}{
+		{
+			name: "no filters",
+			want: []string{"new-checkout", "old-checkout", "search-ranking"},
+		},
+		{
+			name:    "exclude archived",
+			filters: options.FlagFilters{ExcludeArchived: true},
+			want:    []string{"new-checkout", "search-ranking"},
+		},
+		{
+			name:    "tags",
+			filters: options.FlagFilters{Tags: []string{"frontend"}},
+			want:    []string{"new-checkout"},
+		},
+		{
+			name:    "projects and owners",
+			filters: options.FlagFilters{Projects: []string{"checkout"}, Owners: []string{"joe"}},
+			want:    []string{"old-checkout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, excluded := filterFlags(flags, tt.filters)
+			require.Equal(t, tt.want, Keys(got))
+			require.Len(t, excluded, len(flags)-len(tt.want))
+		})
+	}
+}
+
+func TestGetFlagsByProject(t *testing.T) {
+	projects := []options.Project{{Key: "checkout", Dir: "checkout"}, {Key: "search", Dir: "search"}}
+
+	t.Run("flags with project metadata", func(t *testing.T) {
+		got, err := GetFlagsByProject(context.Background(), options.Options{FlagsPath: "testdata/flags_with_metadata.json", Projects: projects})
+		require.NoError(t, err)
+		require.Equal(t, []string{"new-checkout", "old-checkout", "plain-key"}, Keys(got["checkout"]))
+		// flags without a project are searched in every project
+		require.Equal(t, []string{"plain-key"}, Keys(got["search"]))
+	})
+
+	t.Run("flags without project metadata", func(t *testing.T) {
+		got, err := GetFlagsByProject(context.Background(), options.Options{FlagsPath: "testdata/sdk_payload.json", Projects: projects})
+		require.NoError(t, err)
+		want := []string{"dark-mode", "new-checkout", "checkout-button-color"}
+		require.Equal(t, want, Keys(got["checkout"]))
+		require.Equal(t, want, Keys(got["search"]))
+	})
+}
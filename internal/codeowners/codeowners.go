@@ -0,0 +1,121 @@
+// Package codeowners matches paths against the rules of a GitHub CODEOWNERS file
+package codeowners
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Locations are the paths, relative to the repository root, where GitHub looks for a CODEOWNERS file, in order
+var Locations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type rule struct {
+	pattern string
+	owners  []string
+}
+
+// Ruleset is the list of rules of a CODEOWNERS file
+type Ruleset struct {
+	Path  string
+	rules []rule
+}
+
+// Load parses the first CODEOWNERS file found in Locations under dir. It returns nil if there is none.
+func Load(dir string) (*Ruleset, error) {
+	return Find(func(location string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(location)))
+	})
+}
+
+// Find parses the first CODEOWNERS file of Locations that open finds. open must return an error wrapping
+// fs.ErrNotExist for missing files. Find returns nil if there is no CODEOWNERS file.
+func Find(open func(location string) (io.ReadCloser, error)) (*Ruleset, error) {
+	for _, location := range Locations {
+		f, err := open(location)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		ruleset, err := Parse(f)
+		if err != nil {
+			return nil, err
+		}
+		ruleset.Path = location
+		return ruleset, nil
+	}
+	return nil, nil
+}
+
+// Parse reads the rules of a CODEOWNERS file. Blank lines and comments are skipped.
+func Parse(r io.Reader) (*Ruleset, error) {
+	ruleset := &Ruleset{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		owners := []string{}
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break
+			}
+			owners = append(owners, owner)
+		}
+		ruleset.rules = append(ruleset.rules, rule{pattern: fields[0], owners: owners})
+	}
+	return ruleset, scanner.Err()
+}
+
+// Owners returns the owners of a slash-separated path relative to the repository root. As on GitHub, the last
+// matching rule takes precedence, and a matching rule without owners leaves the path without owners.
+func (r *Ruleset) Owners(path string) []string {
+	if r == nil {
+		return nil
+	}
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		if matches(r.rules[i].pattern, path) {
+			return r.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// matches reports whether a CODEOWNERS pattern, which follows most gitignore rules, matches a file path. Patterns
+// matching a directory match every file within it.
+func matches(pattern, path string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	// patterns containing a slash are relative to the repository root, others match at any depth
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		pattern = "**/" + pattern
+	}
+	if pattern == "" {
+		return false
+	}
+
+	if !dirOnly {
+		if ok, _ := doublestar.Match(pattern, path); ok {
+			return true
+		}
+	}
+	// a trailing "/*" matches the files of a directory, but not those of its subdirectories
+	if strings.HasSuffix(pattern, "/*") {
+		return false
+	}
+	ok, _ := doublestar.Match(pattern+"/**", path)
+	return ok
+}
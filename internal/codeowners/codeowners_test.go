@@ -0,0 +1,67 @@
+package codeowners
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCodeowners = `# default owners
+*       @org/everyone
+
+*.js    @org/frontend # inline comment
+/build/ @org/infra
+docs/*  docs@example.com
+apps/   @org/apps
+/apps/github
+**/logs @org/ops
+`
+
+func TestOwners(t *testing.T) {
+	ruleset, err := Parse(strings.NewReader(testCodeowners))
+	require.NoError(t, err)
+
+	specs := []struct {
+		path     string
+		expected []string
+	}{
+		{"README.md", []string{"@org/everyone"}},
+		{"web/app.js", []string{"@org/frontend"}},
+		{"build/app.js", []string{"@org/infra"}},
+		{"src/build/main.go", []string{"@org/everyone"}},
+		{"docs/index.md", []string{"docs@example.com"}},
+		{"docs/guides/index.md", []string{"@org/everyone"}},
+		{"apps/search/main.go", []string{"@org/apps"}},
+		{"src/apps/main.go", []string{"@org/apps"}},
+		// the last matching rule has no owners
+		{"apps/github/main.go", []string{}},
+		{"deploy/logs/out.txt", []string{"@org/ops"}},
+	}
+	for _, tt := range specs {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.expected, ruleset.Owners(tt.path))
+		})
+	}
+
+	var empty *Ruleset
+	require.Nil(t, empty.Owners("README.md"))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	ruleset, err := Load(dir)
+	require.NoError(t, err)
+	require.Nil(t, ruleset)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "CODEOWNERS"), []byte("* @docs"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CODEOWNERS"), []byte("* @root"), 0o600))
+
+	ruleset, err = Load(dir)
+	require.NoError(t, err)
+	require.Equal(t, "CODEOWNERS", ruleset.Path)
+	require.Equal(t, []string{"@root"}, ruleset.Owners("main.go"))
+}
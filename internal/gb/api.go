@@ -0,0 +1,184 @@
+package gb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/internal/version"
+)
+
+const (
+	DefaultApiHost = "https://api.growthbook.io"
+
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryWait  = 500 * time.Millisecond
+
+	featuresPageSize = 100
+)
+
+type ApiOptions struct {
+	Host       string
+	ApiKey     string
+	Timeout    time.Duration
+	MaxRetries int
+	RetryWait  time.Duration
+}
+
+// ApiClient is a minimal client for the GrowthBook REST API
+type ApiClient struct {
+	host       string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+}
+
+func NewApiClient(opts ApiOptions) ApiClient {
+	host := strings.TrimSuffix(opts.Host, "/")
+	if host == "" {
+		host = DefaultApiHost
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	retryWait := opts.RetryWait
+	if retryWait <= 0 {
+		retryWait = defaultRetryWait
+	}
+
+	return ApiClient{
+		host:       host,
+		apiKey:     opts.ApiKey,
+		httpClient: &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		retryWait:  retryWait,
+	}
+}
+
+type featureRep struct {
+	Id       string   `json:"id"`
+	Project  string   `json:"project"`
+	Owner    string   `json:"owner"`
+	Tags     []string `json:"tags"`
+	Archived bool     `json:"archived"`
+}
+
+type featuresPageRep struct {
+	Features   []featureRep `json:"features"`
+	HasMore    bool         `json:"hasMore"`
+	NextOffset *int         `json:"nextOffset"`
+}
+
+// GetFlags returns all features, following pagination until every page has been read
+func (c ApiClient) GetFlags(ctx context.Context) ([]FlagRep, error) {
+	flags := []FlagRep{}
+	offset := 0
+	for {
+		params := url.Values{}
+		params.Set("limit", strconv.Itoa(featuresPageSize))
+		params.Set("offset", strconv.Itoa(offset))
+
+		var page featuresPageRep
+		if err := c.getJSON(ctx, "/api/v1/features?"+params.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, f := range page.Features {
+			flags = append(flags, FlagRep{
+				Key:      f.Id,
+				Project:  f.Project,
+				Owner:    f.Owner,
+				Tags:     f.Tags,
+				Archived: f.Archived,
+			})
+		}
+
+		if !page.HasMore || len(page.Features) == 0 {
+			break
+		}
+		if page.NextOffset != nil {
+			offset = *page.NextOffset
+		} else {
+			offset += len(page.Features)
+		}
+	}
+	log.Debug.Printf("fetched %d flags from %s", len(flags), c.host)
+	return flags, nil
+}
+
+func (c ApiClient) getJSON(ctx context.Context, path string, v interface{}) error {
+	res, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, c.host+path, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends the request built by newReq, retrying with exponential backoff on network errors, rate limiting and server errors
+func (c ApiClient) do(ctx context.Context, newReq func() (*http.Request, error)) (*http.Response, error) {
+	wait := c.retryWait
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			log.Debug.Printf("retrying request in %s (attempt %d of %d): %s", wait, attempt, c.maxRetries, lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		req.Header.Set("User-Agent", "gb-find-code-refs/"+version.Version)
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError {
+			lastErr = responseError(req, res)
+			continue
+		}
+		if res.StatusCode >= http.StatusBadRequest {
+			return nil, responseError(req, res)
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("giving up after %d retries: %w", c.maxRetries, lastErr)
+}
+
+func responseError(req *http.Request, res *http.Response) error {
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("%s %s: unexpected status %d: %s", req.Method, req.URL.Path, res.StatusCode, strings.TrimSpace(string(body)))
+}
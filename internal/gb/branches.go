@@ -0,0 +1,12 @@
+package gb
+
+// BranchesRep lists the remote branches of a repository scanned in one run of the branches command. Deleted
+// branches no longer exist on the remote, so their code references may be garbage collected.
+type BranchesRep struct {
+	RepoName string   `json:"repoName,omitempty"`
+	SyncTime int64    `json:"syncTime"`
+	Remote   []string `json:"remote"`
+	Scanned  []string `json:"scanned"`
+	Failed   []string `json:"failed,omitempty"`
+	Deleted  []string `json:"deleted"`
+}
@@ -40,16 +40,46 @@ func (b BranchRep) TotalHunkCount() int {
 	return count
 }
 
+// OutputJSON is the code references document for a branch, as written to JSON files and uploaded to GrowthBook
 type OutputJSON struct {
-	Branch   string    `json:"branch"`
-	RepoName string    `json:"repoName,omitempty"`
-	Refs     []HunkRep `json:"refs"`
+	Branch        string            `json:"branch"`
+	RepoName      string            `json:"repoName,omitempty"`
+	Head          string            `json:"head,omitempty"`
+	CommitTime    int64             `json:"commitTime,omitempty"`
+	SyncTime      int64             `json:"syncTime,omitempty"`
+	Refs          []HunkRep         `json:"refs"`
+	Extinctions   []ExtinctionRep   `json:"extinctions,omitempty"`
+	Introductions []IntroductionRep `json:"introductions,omitempty"`
 }
 
 func (b BranchRep) WriteToJSON(outDir string, opts options.Options) (path string, err error) {
+	return b.writeOutput(outDir, opts, jsonWriter{}, Report{Branch: b, RepoName: opts.RepoName})
+}
+
+// Records returns the hunks of all references sorted by flag key, path and starting line number
+func (b BranchRep) Records() []HunkRep {
+	records := make([]HunkRep, 0, len(b.References)+1)
+	for _, ref := range b.References {
+		records = append(records, ref.toRecords()...)
+	}
+
+	// sort by flagKey -> path -> startingLineNumber
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].FlagKey != records[j].FlagKey {
+			return records[i].FlagKey < records[j].FlagKey
+		}
+		if records[i].FilePath != records[j].FilePath {
+			return records[i].FilePath < records[j].FilePath
+		}
+		return records[i].StartingLineNumber < records[j].StartingLineNumber
+	})
+	return records
+}
+
+// outputPath returns the path of the output file with the given extension
+func (b BranchRep) outputPath(outDir string, opts options.Options, ext string) (string, error) {
 	sha := opts.Revision
 	outFile := opts.OutFile
-	repoName := opts.RepoName
 
 	// Try to create a filename with a shortened sha, but if the sha is too short for some unexpected reason, use the branch name instead
 	var tag string
@@ -67,69 +97,120 @@ func (b BranchRep) WriteToJSON(outDir string, opts options.Options) (path string
 	var filename string
 	if outFile != "" {
 		filename = outFile
+		if ext != "json" {
+			filename = strings.TrimSuffix(outFile, filepath.Ext(outFile)) + "." + ext
+		}
 	} else {
 		// replace any forward slashes in filename
-		filename = strings.ReplaceAll(fmt.Sprintf("coderefs_%s.json", tag), "/", "_")
+		filename = strings.ReplaceAll(fmt.Sprintf("coderefs_%s.%s", tag, ext), "/", "_")
 	}
-	path = filepath.Join(absPath, filename)
+	return filepath.Join(absPath, filename), nil
+}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
+// ReferenceChangeRep is a line added or removed by a change that references a flag
+type ReferenceChangeRep struct {
+	FilePath   string   `json:"filePath"`
+	LineNumber int      `json:"lineNumber"`
+	Line       string   `json:"line"`
+	FlagKey    string   `json:"flagKey"`
+	Aliases    []string `json:"aliases,omitempty"`
+	ProjKey    string   `json:"projKey,omitempty"`
+}
 
-	records := make([]HunkRep, 0, len(b.References)+1)
-	for _, ref := range b.References {
-		records = append(records, ref.toRecords()...)
-	}
+// DiffRep describes the code references added and removed between a base revision and the head commit of a branch.
+// Changes that have not been committed are not included.
+type DiffRep struct {
+	Branch       string               `json:"branch"`
+	RepoName     string               `json:"repoName,omitempty"`
+	Base         string               `json:"base"`
+	Head         string               `json:"head"`
+	ChangedFiles []string             `json:"changedFiles"`
+	Added        []ReferenceChangeRep `json:"added"`
+	Removed      []ReferenceChangeRep `json:"removed"`
+	Delta        []ReferenceDeltaRep  `json:"delta"`
+}
 
-	// sort records by flag key
-	sort.Slice(records, func(i, j int) bool {
-		// sort by flagKey -> path -> startingLineNumber
-		for k := 0; k < 3; k++ {
-			if records[i].FlagKey != records[j].FlagKey {
-				return records[i].FlagKey < records[j].FlagKey
-			}
-		}
-		// above loop should always return since startingLineNumber is guaranteed to be unique
-		return false
-	})
+// ReferenceDeltaRep is the net number of references to a flag added by a change, in one configured project
+type ReferenceDeltaRep struct {
+	FlagKey string `json:"flagKey"`
+	ProjKey string `json:"projKey,omitempty"`
+	Delta   int    `json:"delta"`
+}
 
-	output := OutputJSON{
-		Branch:   b.Name,
-		RepoName: repoName,
-		Refs:     records,
+func (d DiffRep) WriteToJSON(outDir string, opts options.Options) (path string, err error) {
+	absPath, err := validation.NormalizeAndValidatePath(outDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid outDir '%s': %w", outDir, err)
 	}
 
-	r, err := json.Marshal(output)
+	// replace any forward slashes in filename
+	filename := strings.ReplaceAll(fmt.Sprintf("coderefs_diff_%s.json", d.Branch), "/", "_")
+	path = filepath.Join(absPath, filename)
+
+	d.RepoName = opts.RepoName
+	r, err := json.Marshal(d)
 	if err != nil {
 		return "", err
 	}
 
-	_, err = f.Write(r)
-
-	return path, err
+	return path, os.WriteFile(path, r, 0o644)
 }
 
 type ReferenceHunksRep struct {
 	Path  string    `json:"path"`
 	Hunks []HunkRep `json:"hunks"`
+
+	// Owners are the code owners of the file, from the CODEOWNERS file of the repository
+	Owners []string `json:"owners,omitempty"`
 }
 
 func (r ReferenceHunksRep) toRecords() []HunkRep {
 	ret := make([]HunkRep, 0, len(r.Hunks))
-	ret = append(ret, r.Hunks...)
+	for _, hunk := range r.Hunks {
+		if hunk.FilePath == "" {
+			hunk.FilePath = r.Path
+		}
+		if hunk.CodeOwners == nil {
+			hunk.CodeOwners = r.Owners
+		}
+		ret = append(ret, hunk)
+	}
 	return ret
 }
 
+// HunkRep is a code reference to a flag. FlagProject is the GrowthBook project of the flag, taken from the flag
+// metadata, while ProjKey is the key of the configured project (a subdirectory of the repository) it was found in.
 type HunkRep struct {
-	FilePath           string   `json:"filePath"`
-	StartingLineNumber int      `json:"startingLineNumber"`
-	Lines              string   `json:"lines,omitempty"`
-	FlagKey            string   `json:"flagKey"`
-	Aliases            []string `json:"aliases,omitempty"`
-	ContentHash        string   `json:"contentHash,omitempty"`
+	FilePath           string    `json:"filePath"`
+	StartingLineNumber int       `json:"startingLineNumber"`
+	Lines              string    `json:"lines,omitempty"`
+	FlagKey            string    `json:"flagKey"`
+	Aliases            []string  `json:"aliases,omitempty"`
+	ContentHash        string    `json:"contentHash,omitempty"`
+	FlagProject        string    `json:"flagProject,omitempty"`
+	Owner              string    `json:"owner,omitempty"`
+	Tags               []string  `json:"tags,omitempty"`
+	Archived           bool      `json:"archived,omitempty"`
+	ProjKey            string    `json:"projKey,omitempty"`
+	Blame              *BlameRep `json:"blame,omitempty"`
+	CodeOwners         []string  `json:"codeOwners,omitempty"`
+}
+
+// BlameRep is the last commit that changed a line
+type BlameRep struct {
+	Revision string `json:"revision"`
+	Author   string `json:"author"`
+	Email    string `json:"email,omitempty"`
+	Time     int64  `json:"time"`
+}
+
+// WithFlag copies flag metadata onto the hunk
+func (h HunkRep) WithFlag(flag FlagRep) HunkRep {
+	h.FlagProject = flag.Project
+	h.Owner = flag.Owner
+	h.Tags = flag.Tags
+	h.Archived = flag.Archived
+	return h
 }
 
 // Returns the number of lines overlapping between the receiver (h) and the parameter (hr) hunkreps
@@ -142,11 +223,54 @@ func (h HunkRep) NumLines() int {
 	return strings.Count(h.Lines, "\n") + 1
 }
 
+// ReferenceLineNumbers returns the numbers of the lines of the hunk containing its flag key or one of its aliases,
+// skipping context lines. If the hunk has no lines, its starting line number is returned.
+func (h HunkRep) ReferenceLineNumbers() []int {
+	ret := []int{}
+	for i, line := range strings.Split(h.Lines, "\n") {
+		if strings.Contains(line, h.FlagKey) {
+			ret = append(ret, h.StartingLineNumber+i)
+			continue
+		}
+		for _, alias := range h.Aliases {
+			if strings.Contains(line, alias) {
+				ret = append(ret, h.StartingLineNumber+i)
+				break
+			}
+		}
+	}
+	if len(ret) == 0 {
+		ret = append(ret, h.StartingLineNumber)
+	}
+	return ret
+}
+
+// FlagRep is a flag to search for, along with metadata that is carried through to its code references
+type FlagRep struct {
+	Key      string   `json:"key"`
+	Project  string   `json:"project,omitempty"`
+	Owner    string   `json:"owner,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Archived bool     `json:"archived,omitempty"`
+}
+
 type ExtinctionRep struct {
 	Revision string `json:"revision"`
 	Message  string `json:"message"`
 	Time     int64  `json:"time"`
+	Author   string `json:"author,omitempty"`
 	FlagKey  string `json:"flagKey"`
+	ProjKey  string `json:"projKey,omitempty"`
+}
+
+// IntroductionRep is the commit that first added a reference to a flag
+type IntroductionRep struct {
+	Revision string `json:"revision"`
+	Message  string `json:"message"`
+	Time     int64  `json:"time"`
+	Author   string `json:"author,omitempty"`
+	FlagKey  string `json:"flagKey"`
+	ProjKey  string `json:"projKey,omitempty"`
 }
 
 type tableData [][]string
@@ -191,6 +315,25 @@ func (b BranchRep) CountByFlag(matcher [][]string) map[string]int64 {
 	return refCountByFlag
 }
 
+// CountByProjectAndFlag counts references for each flag of each project, including flags without any references
+func (b BranchRep) CountByProjectAndFlag(elementsByProject map[string][]string) map[string]map[string]int64 {
+	refCountByProjectAndFlag := make(map[string]map[string]int64, len(elementsByProject))
+	for projKey, flags := range elementsByProject {
+		refCountByProjectAndFlag[projKey] = make(map[string]int64, len(flags))
+		for _, flag := range flags {
+			refCountByProjectAndFlag[projKey][flag] = 0
+		}
+	}
+	for _, ref := range b.References {
+		for _, hunk := range ref.Hunks {
+			if refCountByFlag, ok := refCountByProjectAndFlag[hunk.ProjKey]; ok {
+				refCountByFlag[hunk.FlagKey]++
+			}
+		}
+	}
+	return refCountByProjectAndFlag
+}
+
 func (b BranchRep) PrintReferenceCountTable() {
 	data := tableData{}
 
@@ -217,3 +360,61 @@ func (b BranchRep) PrintReferenceCountTable() {
 	table.AppendBulk(truncatedData)
 	table.Render()
 }
+
+// PrintOwnerTable prints the number of references and flags in the files of each code owner. Nothing is printed if
+// no file has code owners.
+func (b BranchRep) PrintOwnerTable() {
+	refCountByOwner, flagCountByOwner := b.CountByOwner()
+	if len(refCountByOwner) == 0 {
+		return
+	}
+	ownerData := tableData{}
+	for owner, count := range refCountByOwner {
+		name := owner
+		if name == "" {
+			name = "No code owners"
+		}
+		ownerData = append(ownerData, []string{name, strconv.FormatInt(count, 10), strconv.Itoa(flagCountByOwner[owner])})
+	}
+	sort.Slice(ownerData, func(i, j int) bool { return ownerData[i][0] < ownerData[j][0] })
+	sort.Stable(ownerData)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Code owner", "# References", "# Flags"})
+	table.SetBorder(false)
+	table.AppendBulk(ownerData)
+	table.Render()
+}
+
+// CountByOwner counts the references and the distinct flags in the files of each code owner. References in files
+// without code owners are counted under the empty owner. Both maps are empty if no file has code owners.
+func (b BranchRep) CountByOwner() (map[string]int64, map[string]int) {
+	refCountByOwner := map[string]int64{}
+	flagsByOwner := map[string]map[string]bool{}
+	hasOwners := false
+	for _, ref := range b.References {
+		owners := ref.Owners
+		if len(owners) == 0 {
+			owners = []string{""}
+		} else {
+			hasOwners = true
+		}
+		for _, owner := range owners {
+			if flagsByOwner[owner] == nil {
+				flagsByOwner[owner] = map[string]bool{}
+			}
+			for _, hunk := range ref.Hunks {
+				refCountByOwner[owner]++
+				flagsByOwner[owner][hunk.ProjKey+"/"+hunk.FlagKey] = true
+			}
+		}
+	}
+	if !hasOwners {
+		return map[string]int64{}, map[string]int{}
+	}
+	flagCountByOwner := make(map[string]int, len(flagsByOwner))
+	for owner, flags := range flagsByOwner {
+		flagCountByOwner[owner] = len(flags)
+	}
+	return refCountByOwner, flagCountByOwner
+}
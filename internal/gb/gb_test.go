@@ -1,12 +1,23 @@
 package gb
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/options"
 )
 
 func TestMain(m *testing.M) {
@@ -72,3 +83,320 @@ func TestCountByProjectAndFlag(t *testing.T) {
 	require.Equal(t, count, want)
 
 }
+
+func TestCountByProjectAndFlag_MultipleProjects(t *testing.T) {
+	b := BranchRep{
+		References: []ReferenceHunksRep{{
+			Hunks: []HunkRep{
+				{FlagKey: "webFlag", ProjKey: "web"},
+				{FlagKey: "webFlag", ProjKey: "web"},
+				{FlagKey: "apiFlag", ProjKey: "api"},
+			},
+		}},
+	}
+	count := b.CountByProjectAndFlag(map[string][]string{
+		"web": {"webFlag", "unusedFlag"},
+		"api": {"apiFlag"},
+	})
+	want := map[string]map[string]int64{
+		"web": {"webFlag": 2, "unusedFlag": 0},
+		"api": {"apiFlag": 1},
+	}
+	require.Equal(t, want, count)
+}
+
+func TestApiClient_GetFlags(t *testing.T) {
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/features", r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Query().Get("offset") {
+		case "0":
+			fmt.Fprint(w, `{"features":[{"id":"flag-one","project":"prj_1","owner":"jane","tags":["frontend"]},{"id":"flag-two","archived":true}],"hasMore":true,"nextOffset":2}`)
+		case "2":
+			fmt.Fprint(w, `{"features":[{"id":"flag-three"}],"hasMore":false,"nextOffset":null}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := NewApiClient(ApiOptions{Host: server.URL, ApiKey: "secret", RetryWait: time.Millisecond})
+	flags, err := client.GetFlags(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []FlagRep{
+		{Key: "flag-one", Project: "prj_1", Owner: "jane", Tags: []string{"frontend"}},
+		{Key: "flag-two", Archived: true},
+		{Key: "flag-three"},
+	}, flags)
+}
+
+func TestApiClient_GetFlags_ClientError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewApiClient(ApiOptions{Host: server.URL, RetryWait: time.Millisecond})
+	_, err := client.GetFlags(context.Background())
+	require.Error(t, err)
+	require.Equal(t, 1, requests, "client errors should not be retried")
+}
+
+func TestPrepareUpload(t *testing.T) {
+	refs := []HunkRep{}
+	for i := 0; i < maxUploadChunkHunkCount-1; i++ {
+		refs = append(refs, HunkRep{FlagKey: "flag-a", StartingLineNumber: i})
+	}
+	refs = append(refs, HunkRep{FlagKey: "flag-b"}, HunkRep{FlagKey: "flag-b"}, HunkRep{FlagKey: "flag-c"})
+
+	extinctions := []ExtinctionRep{{FlagKey: "flag-d", Revision: "123"}}
+	introductions := []IntroductionRep{{FlagKey: "flag-a", Revision: "456"}}
+	requests, err := PrepareUpload(OutputJSON{Branch: "main", RepoName: "repo", Head: "abc", Refs: refs, Extinctions: extinctions, Introductions: introductions})
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	require.Equal(t, extinctions, requests[0].Output.Extinctions)
+	require.Equal(t, introductions, requests[0].Output.Introductions)
+	require.Empty(t, requests[1].Output.Extinctions)
+	require.Empty(t, requests[1].Output.Introductions)
+	require.Equal(t, "abc", requests[1].Output.Head)
+	// references to flag-b do not fit in the first request, and are not split between requests
+	require.Len(t, requests[0].Output.Refs, maxUploadChunkHunkCount-1)
+	require.Len(t, requests[1].Output.Refs, 3)
+	require.Equal(t, "main", requests[1].Output.Branch)
+	require.Equal(t, "repo", requests[1].Output.RepoName)
+}
+
+func TestApiClient_UploadCodeRefs(t *testing.T) {
+	failures := 1
+	received := []OutputJSON{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, CodeRefsPath, r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var output OutputJSON
+		require.NoError(t, json.NewDecoder(zr).Decode(&output))
+		received = append(received, output)
+	}))
+	defer server.Close()
+
+	requests, err := PrepareUpload(testReport().ToOutputJSON())
+	require.NoError(t, err)
+
+	client := NewApiClient(ApiOptions{Host: server.URL, ApiKey: "secret", RetryWait: time.Millisecond})
+	require.NoError(t, client.UploadCodeRefs(context.Background(), requests))
+	require.Len(t, received, 1)
+	require.Equal(t, "main", received[0].Branch)
+	require.Len(t, received[0].Refs, 3)
+	require.Len(t, received[0].Extinctions, 1)
+}
+
+func TestJSONWriter(t *testing.T) {
+	report := testReport()
+	report.Branch.Head = "abc"
+	report.Branch.CommitTime = 1
+	report.Branch.SyncTime = 2
+
+	var buf bytes.Buffer
+	require.NoError(t, jsonWriter{}.Write(&buf, report))
+
+	var output OutputJSON
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+	require.Equal(t, "main", output.Branch)
+	require.Equal(t, "repo", output.RepoName)
+	require.Equal(t, "abc", output.Head)
+	require.Equal(t, int64(1), output.CommitTime)
+	require.Equal(t, int64(2), output.SyncTime)
+	require.Len(t, output.Refs, 3)
+	require.Equal(t, report.Extinctions, output.Extinctions)
+}
+
+func TestToSARIF(t *testing.T) {
+	b := BranchRep{
+		References: []ReferenceHunksRep{{
+			Path: "src/app.ts",
+			Hunks: []HunkRep{
+				{FlagKey: "flag-b", StartingLineNumber: 3, Lines: "a\nflagB\nc", Aliases: []string{"flagB"}, ContentHash: "abc"},
+				{FlagKey: "flag-a", StartingLineNumber: 10, Lines: "d"},
+			},
+		}},
+	}
+
+	log := b.toSARIF()
+	require.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+
+	ruleIds := []string{}
+	for _, rule := range run.Tool.Driver.Rules {
+		ruleIds = append(ruleIds, rule.Id)
+	}
+	require.Equal(t, []string{"flag-a", "flag-b"}, ruleIds)
+
+	require.Len(t, run.Results, 2)
+	first := run.Results[0]
+	require.Equal(t, "flag-b", first.RuleId)
+	require.Len(t, first.Locations, 1)
+	require.Equal(t, "src/app.ts", first.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	// the region is the line referencing the flag, not the context lines of the hunk
+	require.Equal(t, sarifRegion{StartLine: 4, EndLine: 4}, first.Locations[0].PhysicalLocation.Region)
+	require.Equal(t, map[string]string{"contentHash": "abc"}, first.PartialFingerprints)
+	require.Equal(t, []string{"flagB"}, first.Properties["aliases"])
+	require.Nil(t, run.Results[1].PartialFingerprints)
+
+	// extinct flags have no location, so they are not reported
+	var out strings.Builder
+	require.NoError(t, sarifWriter{}.Write(&out, Report{Branch: b, Extinctions: []ExtinctionRep{{FlagKey: "flag-c", Revision: "123"}}}))
+	require.NotContains(t, out.String(), "flag-c")
+}
+
+func testReport() Report {
+	return Report{
+		RepoName: "repo",
+		Branch: BranchRep{
+			Name: "main",
+			References: []ReferenceHunksRep{
+				{
+					Path: "src/b.ts",
+					Hunks: []HunkRep{
+						{FlagKey: "flag-b", StartingLineNumber: 7, Lines: "x", ContentHash: "h2"},
+					},
+				},
+				{
+					Path: "src/a.ts",
+					Hunks: []HunkRep{
+						{FlagKey: "flag-b", StartingLineNumber: 3, Lines: "a", Aliases: []string{"flagB", "FLAG_B"}, ContentHash: "h1"},
+						{FlagKey: "flag-a", StartingLineNumber: 10, Lines: "d", ContentHash: "h3"},
+					},
+				},
+			},
+		},
+		Extinctions: []ExtinctionRep{{FlagKey: "flag-c", Revision: "123", Message: "remove flag-c\n\nlong description"}},
+	}
+}
+
+func TestNewOutputWriter(t *testing.T) {
+	for _, format := range options.OutputFormats {
+		writer, err := NewOutputWriter(format)
+		require.NoError(t, err, format)
+		require.NotEmpty(t, writer.Extension())
+	}
+	_, err := NewOutputWriter("xml")
+	require.Error(t, err)
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, csvWriter{}.Write(&buf, testReport()))
+	require.Equal(t, `flagKey,path,line,aliases,hash
+flag-a,src/a.ts,10,,h3
+flag-b,src/a.ts,3,flagB;FLAG_B,h1
+flag-b,src/b.ts,7,,h2
+`, buf.String())
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, ndjsonWriter{}.Write(&buf, testReport()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	var hunk HunkRep
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &hunk))
+	require.Equal(t, "flag-b", hunk.FlagKey)
+	require.Equal(t, "src/a.ts", hunk.FilePath)
+	require.Equal(t, 3, hunk.StartingLineNumber)
+}
+
+func TestMarkdownWriter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, markdownWriter{}.Write(&buf, testReport()))
+
+	out := buf.String()
+	require.Contains(t, out, "# Code references for `repo@main`")
+	require.Contains(t, out, "Found **3** code references to **2** flags across **2** files.")
+	require.Contains(t, out, "| `flag-b` | 2 | 2 |")
+	require.Contains(t, out, "| `flag-c` | `123` | remove flag-c |")
+	require.Less(t, strings.Index(out, "`flag-b`"), strings.Index(out, "`flag-a`"))
+}
+
+func TestWriteOutputs(t *testing.T) {
+	dir := t.TempDir()
+	report := testReport()
+	opts := options.Options{Formats: []string{"json", "csv", "markdown"}}
+
+	paths, err := report.Branch.WriteOutputs(dir, opts, nil)
+	require.NoError(t, err)
+	require.Len(t, paths, 3)
+	require.Equal(t, "coderefs_main.json", filepath.Base(paths[0]))
+	require.Equal(t, "coderefs_main.csv", filepath.Base(paths[1]))
+	require.Equal(t, "coderefs_main.md", filepath.Base(paths[2]))
+	for _, path := range paths {
+		_, err := os.Stat(path)
+		require.NoError(t, err)
+	}
+}
+
+func TestStaleRep_WriteTable(t *testing.T) {
+	stale := StaleRep{
+		Branch: "main",
+		Flags: []StaleFlagRep{
+			{FlagKey: "old-checkout", Revision: "3f1e9d0c4b2a", Author: "Jane", Time: 1700000000000},
+			{FlagKey: "unused-flag"},
+		},
+	}
+	var buf bytes.Buffer
+	stale.WriteTable(&buf)
+	require.Contains(t, buf.String(), "old-checkout")
+	require.Contains(t, buf.String(), "2023-11-14")
+	require.Contains(t, buf.String(), "3f1e9d0c ")
+	require.Contains(t, buf.String(), "not found in history")
+	require.NotContains(t, buf.String(), "PROJECT")
+}
+
+func TestHistoryRep_WriteCSV(t *testing.T) {
+	history := HistoryRep{
+		Branch: "main",
+		Points: []HistoryPointRep{
+			{Revision: "abc", Time: 1000, References: 2, Flags: []HistoryCountRep{{FlagKey: "flag-a", References: 2}}},
+			{Revision: "def", Time: 2000, References: 0, Flags: []HistoryCountRep{}},
+			{Revision: "ghi", Time: 3000, References: 4, Flags: []HistoryCountRep{{FlagKey: "flag-a", References: 1}, {FlagKey: "flag-b", ProjKey: "web", References: 3}}},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, history.WriteCSV(&buf))
+	require.Equal(t, "revision,time,projKey,flagKey,references\nabc,1000,,flag-a,2\nghi,3000,,flag-a,1\nghi,3000,web,flag-b,3\n", buf.String())
+}
+
+func TestCountByOwner(t *testing.T) {
+	branch := BranchRep{
+		References: []ReferenceHunksRep{
+			{Path: "a.js", Owners: []string{"@web", "@jane"}, Hunks: []HunkRep{{FlagKey: "flag1"}, {FlagKey: "flag1"}, {FlagKey: "flag2"}}},
+			{Path: "b.go", Owners: []string{"@api"}, Hunks: []HunkRep{{FlagKey: "flag1"}}},
+			{Path: "c.py", Hunks: []HunkRep{{FlagKey: "flag3"}}},
+		},
+	}
+	refCounts, flagCounts := branch.CountByOwner()
+	require.Equal(t, map[string]int64{"@web": 3, "@jane": 3, "@api": 1, "": 1}, refCounts)
+	require.Equal(t, map[string]int{"@web": 2, "@jane": 2, "@api": 1, "": 1}, flagCounts)
+
+	refCounts, flagCounts = BranchRep{References: branch.References[2:]}.CountByOwner()
+	require.Empty(t, refCounts)
+	require.Empty(t, flagCounts)
+}
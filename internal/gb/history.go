@@ -0,0 +1,58 @@
+package gb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// HistoryRep is a time series of the number of references to each flag on a branch
+type HistoryRep struct {
+	Branch string            `json:"branch"`
+	Head   string            `json:"head,omitempty"`
+	Points []HistoryPointRep `json:"points"`
+}
+
+// HistoryPointRep counts the lines referencing each flag at a commit. Flags without references are omitted.
+type HistoryPointRep struct {
+	Revision   string            `json:"revision"`
+	Time       int64             `json:"time"`
+	References int               `json:"references"`
+	Flags      []HistoryCountRep `json:"flags"`
+}
+
+type HistoryCountRep struct {
+	FlagKey    string `json:"flagKey"`
+	ProjKey    string `json:"projKey,omitempty"`
+	References int    `json:"references"`
+}
+
+// WriteJSON writes the history as a single JSON document
+func (h HistoryRep) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(h)
+}
+
+// WriteCSV writes one row per flag with references at each commit, oldest first
+func (h HistoryRep) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"revision", "time", "projKey", "flagKey", "references"}); err != nil {
+		return err
+	}
+	for _, point := range h.Points {
+		for _, count := range point.Flags {
+			err := cw.Write([]string{
+				point.Revision,
+				strconv.FormatInt(point.Time, 10),
+				count.ProjKey,
+				count.FlagKey,
+				strconv.Itoa(count.References),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
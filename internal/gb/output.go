@@ -0,0 +1,234 @@
+package gb
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+// Report is the result of a scan, as written by output writers
+type Report struct {
+	Branch        BranchRep
+	RepoName      string
+	Extinctions   []ExtinctionRep
+	Introductions []IntroductionRep
+}
+
+// ToOutputJSON returns the single document combining the code references, extinctions, introductions and metadata
+// of the branch
+func (r Report) ToOutputJSON() OutputJSON {
+	return OutputJSON{
+		Branch:        r.Branch.Name,
+		RepoName:      r.RepoName,
+		Head:          r.Branch.Head,
+		CommitTime:    r.Branch.CommitTime,
+		SyncTime:      r.Branch.SyncTime,
+		Refs:          r.Branch.Records(),
+		Extinctions:   r.Extinctions,
+		Introductions: r.Introductions,
+	}
+}
+
+// OutputWriter writes a report in a specific output format
+type OutputWriter interface {
+	// Extension is the file extension of the output file, without a leading dot
+	Extension() string
+	Write(w io.Writer, report Report) error
+}
+
+// NewOutputWriter returns the writer for one of options.OutputFormats
+func NewOutputWriter(format string) (OutputWriter, error) {
+	switch format {
+	case "json":
+		return jsonWriter{}, nil
+	case "sarif":
+		return sarifWriter{}, nil
+	case "csv":
+		return csvWriter{}, nil
+	case "ndjson":
+		return ndjsonWriter{}, nil
+	case "markdown":
+		return markdownWriter{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// WriteOutputs writes an output file to outDir for each of the configured formats and returns their paths.
+// If no formats are configured, JSON is written.
+func (b BranchRep) WriteOutputs(outDir string, opts options.Options, extinctions []ExtinctionRep) ([]string, error) {
+	return Report{Branch: b, RepoName: opts.RepoName, Extinctions: extinctions}.WriteOutputs(outDir, opts)
+}
+
+// WriteOutputs writes the report to an output file in outDir for each of the configured formats and returns their
+// paths. If no formats are configured, JSON is written.
+func (r Report) WriteOutputs(outDir string, opts options.Options) ([]string, error) {
+	formats := opts.Formats
+	if len(formats) == 0 {
+		formats = []string{"json"}
+	}
+	paths := make([]string, 0, len(formats))
+	for _, format := range formats {
+		writer, err := NewOutputWriter(format)
+		if err != nil {
+			return paths, err
+		}
+		path, err := r.Branch.writeOutput(outDir, opts, writer, r)
+		if err != nil {
+			return paths, fmt.Errorf("error writing %s output: %w", format, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (b BranchRep) writeOutput(outDir string, opts options.Options, writer OutputWriter, report Report) (path string, err error) {
+	path, err = b.outputPath(outDir, opts, writer.Extension())
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := writer.Write(w, report); err != nil {
+		return "", err
+	}
+	return path, w.Flush()
+}
+
+type jsonWriter struct{}
+
+func (jsonWriter) Extension() string {
+	return "json"
+}
+
+func (jsonWriter) Write(w io.Writer, report Report) error {
+	r, err := json.Marshal(report.ToOutputJSON())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(r)
+	return err
+}
+
+// csvWriter writes one row per hunk
+type csvWriter struct{}
+
+func (csvWriter) Extension() string {
+	return "csv"
+}
+
+func (csvWriter) Write(w io.Writer, report Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"flagKey", "path", "line", "aliases", "hash"}); err != nil {
+		return err
+	}
+	for _, hunk := range report.Branch.Records() {
+		err := cw.Write([]string{
+			hunk.FlagKey,
+			hunk.FilePath,
+			strconv.Itoa(hunk.StartingLineNumber),
+			strings.Join(hunk.Aliases, ";"),
+			hunk.ContentHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ndjsonWriter writes one JSON encoded hunk per line, so that output can be processed as a stream
+type ndjsonWriter struct{}
+
+func (ndjsonWriter) Extension() string {
+	return "ndjson"
+}
+
+func (ndjsonWriter) Write(w io.Writer, report Report) error {
+	enc := json.NewEncoder(w)
+	for _, hunk := range report.Branch.Records() {
+		if err := enc.Encode(hunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownWriter writes a human readable summary of references per flag
+type markdownWriter struct{}
+
+func (markdownWriter) Extension() string {
+	return "md"
+}
+
+func (markdownWriter) Write(w io.Writer, report Report) error {
+	b := report.Branch
+	title := b.Name
+	if report.RepoName != "" {
+		title = report.RepoName + "@" + b.Name
+	}
+
+	type flagSummary struct {
+		key   string
+		refs  int
+		files map[string]bool
+	}
+	summaries := map[string]*flagSummary{}
+	for _, ref := range b.References {
+		for _, hunk := range ref.Hunks {
+			summary, ok := summaries[hunk.FlagKey]
+			if !ok {
+				summary = &flagSummary{key: hunk.FlagKey, files: map[string]bool{}}
+				summaries[hunk.FlagKey] = summary
+			}
+			summary.refs++
+			summary.files[ref.Path] = true
+		}
+	}
+	sorted := make([]*flagSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		sorted = append(sorted, summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].refs != sorted[j].refs {
+			return sorted[i].refs > sorted[j].refs
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	fmt.Fprintf(w, "# Code references for `%s`\n\n", title)
+	if b.Head != "" {
+		fmt.Fprintf(w, "Scanned revision `%s`.\n\n", b.Head)
+	}
+	fmt.Fprintf(w, "Found **%d** code references to **%d** flags across **%d** files.\n", b.TotalHunkCount(), len(sorted), len(b.References))
+
+	if len(sorted) > 0 {
+		fmt.Fprint(w, "\n| Flag | References | Files |\n| --- | ---: | ---: |\n")
+		for _, summary := range sorted {
+			fmt.Fprintf(w, "| `%s` | %d | %d |\n", summary.key, summary.refs, len(summary.files))
+		}
+	}
+
+	if len(report.Extinctions) > 0 {
+		fmt.Fprint(w, "\n## Removed flags\n\n| Flag | Revision | Message |\n| --- | --- | --- |\n")
+		for _, e := range report.Extinctions {
+			message := strings.ReplaceAll(strings.TrimSpace(strings.SplitN(e.Message, "\n", 2)[0]), "|", "\\|")
+			fmt.Fprintf(w, "| `%s` | `%s` | %s |\n", e.FlagKey, e.Revision, message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package gb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/growthbook/gb-find-code-refs/internal/version"
+	"github.com/growthbook/gb-find-code-refs/options"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId              string                 `json:"ruleId"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// WriteToSARIF writes code references as a SARIF 2.1.0 log, with one result per hunk and the flag key as the rule id.
+// Each result is located at the lines of the hunk that reference the flag. Extinct flags are not reported, since
+// code scanning tools require results to have a location in the scanned files.
+func (b BranchRep) WriteToSARIF(outDir string, opts options.Options) (path string, err error) {
+	return b.writeOutput(outDir, opts, sarifWriter{}, Report{Branch: b, RepoName: opts.RepoName})
+}
+
+type sarifWriter struct{}
+
+func (sarifWriter) Extension() string {
+	return "sarif"
+}
+
+func (sarifWriter) Write(w io.Writer, report Report) error {
+	return json.NewEncoder(w).Encode(report.Branch.toSARIF())
+}
+
+func (b BranchRep) toSARIF() sarifLog {
+	ruleIds := map[string]bool{}
+	results := []sarifResult{}
+	for _, ref := range b.References {
+		for _, hunk := range ref.Hunks {
+			ruleIds[hunk.FlagKey] = true
+			properties := map[string]interface{}{}
+			if len(hunk.Aliases) > 0 {
+				properties["aliases"] = hunk.Aliases
+			}
+			if hunk.ProjKey != "" {
+				properties["projKey"] = hunk.ProjKey
+			}
+			locations := []sarifLocation{}
+			for _, line := range hunk.ReferenceLineNumbers() {
+				locations = append(locations, sarifLocation{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: ref.Path},
+						Region:           sarifRegion{StartLine: line, EndLine: line},
+					},
+				})
+			}
+			var fingerprints map[string]string
+			if hunk.ContentHash != "" {
+				fingerprints = map[string]string{"contentHash": hunk.ContentHash}
+			}
+			results = append(results, sarifResult{
+				RuleId:              hunk.FlagKey,
+				Level:               "note",
+				Message:             sarifMessage{Text: fmt.Sprintf("Reference to feature flag '%s'", hunk.FlagKey)},
+				Locations:           locations,
+				PartialFingerprints: fingerprints,
+				Properties:          properties,
+			})
+		}
+	}
+
+	rules := make([]sarifRule, 0, len(ruleIds))
+	for flagKey := range ruleIds {
+		rules = append(rules, sarifRule{
+			Id:               flagKey,
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("Feature flag '%s'", flagKey)},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Id < rules[j].Id
+	})
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "gb-find-code-refs",
+				InformationURI: "https://github.com/growthbook/gb-find-code-refs",
+				Version:        version.Version,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
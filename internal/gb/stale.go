@@ -0,0 +1,63 @@
+package gb
+
+import (
+	"io"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// StaleRep lists the flags without code references on a branch
+type StaleRep struct {
+	Branch string         `json:"branch"`
+	Head   string         `json:"head,omitempty"`
+	Flags  []StaleFlagRep `json:"flags"`
+}
+
+// StaleFlagRep is a flag without code references. If a commit removing its last reference was found in the searched
+// history, the commit is recorded as the last time the flag was seen.
+type StaleFlagRep struct {
+	FlagKey  string `json:"flagKey"`
+	ProjKey  string `json:"projKey,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Time     int64  `json:"time,omitempty"`
+}
+
+// WriteTable writes the stale flags as a table, with flags not seen in the searched history marked as such
+func (s StaleRep) WriteTable(w io.Writer) {
+	hasProjects := false
+	for _, f := range s.Flags {
+		if f.ProjKey != "" {
+			hasProjects = true
+			break
+		}
+	}
+
+	header := []string{"Flag", "Last seen", "Author", "Commit"}
+	if hasProjects {
+		header = append([]string{"Project"}, header...)
+	}
+	data := make([][]string, 0, len(s.Flags))
+	for _, f := range s.Flags {
+		row := []string{f.FlagKey, "not found in history", "", ""}
+		if f.Revision != "" {
+			revision := f.Revision
+			if len(revision) > 8 {
+				revision = revision[:8]
+			}
+			row = []string{f.FlagKey, time.UnixMilli(f.Time).UTC().Format("2006-01-02"), f.Author, revision}
+		}
+		if hasProjects {
+			row = append([]string{f.ProjKey}, row...)
+		}
+		data = append(data, row)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(header)
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -0,0 +1,106 @@
+package gb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+)
+
+const (
+	CodeRefsPath = "/api/v1/code-refs"
+
+	// maxUploadChunkHunkCount keeps each upload request well below the maximum number of hunks a single scan produces
+	maxUploadChunkHunkCount = 5000
+)
+
+// UploadRequest is a single gzipped request body sent to the code references endpoint
+type UploadRequest struct {
+	Output OutputJSON
+	Body   []byte
+}
+
+// PrepareUpload splits the code references of a branch into gzipped request bodies of at most
+// maxUploadChunkHunkCount hunks each. References to the same flag are never split across requests.
+// Every request carries the branch metadata, and extinctions and introductions are sent with the first request.
+func PrepareUpload(output OutputJSON) ([]UploadRequest, error) {
+	newChunk := func() OutputJSON {
+		chunk := output
+		chunk.Refs = []HunkRep{}
+		chunk.Extinctions = nil
+		chunk.Introductions = nil
+		return chunk
+	}
+
+	chunks := []OutputJSON{}
+	chunk := newChunk()
+	chunk.Extinctions = output.Extinctions
+	chunk.Introductions = output.Introductions
+	for i := 0; i < len(output.Refs); {
+		// find all hunks of the current flag; refs are sorted by flag key
+		j := i + 1
+		for j < len(output.Refs) && output.Refs[j].FlagKey == output.Refs[i].FlagKey {
+			j++
+		}
+		if len(chunk.Refs) > 0 && len(chunk.Refs)+j-i > maxUploadChunkHunkCount {
+			chunks = append(chunks, chunk)
+			chunk = newChunk()
+		}
+		chunk.Refs = append(chunk.Refs, output.Refs[i:j]...)
+		i = j
+	}
+	chunks = append(chunks, chunk)
+
+	requests := make([]UploadRequest, 0, len(chunks))
+	for _, c := range chunks {
+		body, err := gzipJSON(c)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, UploadRequest{Output: c, Body: body})
+	}
+	return requests, nil
+}
+
+// UploadCodeRefs sends each prepared request to the code references endpoint
+func (c ApiClient) UploadCodeRefs(ctx context.Context, requests []UploadRequest) error {
+	for i, r := range requests {
+		res, err := c.do(ctx, func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.CodeRefsURL(), bytes.NewReader(r.Body))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", "gzip")
+			return req, nil
+		})
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		log.Debug.Printf("uploaded %d code references (request %d of %d)", len(r.Output.Refs), i+1, len(requests))
+	}
+	return nil
+}
+
+// CodeRefsURL returns the URL code references are uploaded to
+func (c ApiClient) CodeRefsURL() string {
+	return c.host + CodeRefsPath
+}
+
+func gzipJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
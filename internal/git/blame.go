@@ -0,0 +1,116 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+)
+
+// uncommittedRevision is the revision git blame reports for lines that have not been committed
+const uncommittedRevision = "0000000000000000000000000000000000000000"
+
+// BlameReferences records on each hunk the most recent commit that changed one of its lines referencing the flag.
+// Each file is blamed once, however many hunks it contains. Lines that have not been committed are not attributed.
+func (c Client) BlameReferences(ctx context.Context, refs []gb.ReferenceHunksRep) error {
+	for _, ref := range refs {
+		blame, err := c.blameFile(ctx, ref.Path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warning.Printf("unable to blame %s: %s", ref.Path, err)
+			continue
+		}
+		for i, hunk := range ref.Hunks {
+			var newest *gb.BlameRep
+			for _, lineNum := range hunk.ReferenceLineNumbers() {
+				if line, ok := blame[lineNum]; ok && (newest == nil || line.Time > newest.Time) {
+					line := line
+					newest = &line
+				}
+			}
+			ref.Hunks[i].Blame = newest
+		}
+	}
+	return nil
+}
+
+// blameFile returns the last commit that changed each committed line of a file, keyed by line number
+func (c Client) blameFile(ctx context.Context, path string) (map[int]gb.BlameRep, error) {
+	args := []string{"-C", c.workspace, "blame", "--porcelain"}
+	if !c.ref.IsZero() {
+		args = append(args, c.ref.String())
+	}
+	/* #nosec */
+	cmd := exec.CommandContext(ctx, "git", append(args, "--", path)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseBlamePorcelain(out)
+}
+
+// parseBlamePorcelain parses the output of git blame --porcelain. Commit details are only printed the first time
+// a commit is seen, so they are remembered for later lines of the same commit.
+func parseBlamePorcelain(out []byte) (map[int]gb.BlameRep, error) {
+	ret := map[int]gb.BlameRep{}
+	commits := map[string]*gb.BlameRep{}
+	var current *gb.BlameRep
+	var lineNum int
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			// the content of the line ends its entry
+			if current != nil && current.Revision != uncommittedRevision {
+				ret[lineNum] = *current
+			}
+			current = nil
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if current == nil {
+			// header: <revision> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(value)
+			if len(key) != len(uncommittedRevision) || len(fields) < 2 {
+				return nil, fmt.Errorf("unexpected blame output: %q", line)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("unexpected blame output: %q", line)
+			}
+			lineNum = n
+			if commits[key] == nil {
+				commits[key] = &gb.BlameRep{Revision: key}
+			}
+			current = commits[key]
+			continue
+		}
+
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-mail":
+			current.Email = strings.Trim(value, "<>")
+		case "author-time":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected blame output: %q", line)
+			}
+			current.Time = seconds * 1000
+		}
+	}
+	return ret, scanner.Err()
+}
@@ -0,0 +1,96 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Branch is a branch of a remote with a copy in the local repository
+type Branch struct {
+	// Name is the name of the branch on the remote
+	Name string
+
+	// Ref is the local reference to the branch, e.g. refs/remotes/origin/main
+	Ref string
+
+	// Time is the committer time of the head of the branch, in milliseconds
+	Time int64
+}
+
+// NewRepoClient returns a client for the repository at path that does not read the checked out commit, for
+// operations covering several branches. path may be a bare repository.
+func NewRepoClient(path string) (*Client, error) {
+	client := Client{workspace: path}
+	if !filepath.IsAbs(path) {
+		return &client, fmt.Errorf("expected an absolute path but received a relative path: %s", path)
+	}
+	if _, err := git.PlainOpen(path); err != nil {
+		return &client, err
+	}
+	return &client, nil
+}
+
+// TrackedBranches returns the remote branches with a local reference, sorted by name. These are the
+// remote-tracking branches, or the branches of a bare repository such as a mirror. The copy of a branch may be
+// out of date, or remain after the branch was deleted on the remote.
+func (c *Client) TrackedBranches() ([]Branch, error) {
+	repo, err := git.PlainOpen(c.workspace)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+
+	byName := map[string]Branch{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			// symbolic references such as refs/remotes/origin/HEAD point to another branch
+			return nil
+		}
+		var name string
+		switch {
+		case cfg.Core.IsBare && ref.Name().IsBranch():
+			name = ref.Name().Short()
+		case !cfg.Core.IsBare && ref.Name().IsRemote():
+			// refs/remotes/<remote>/<branch>
+			_, name, _ = strings.Cut(strings.TrimPrefix(ref.Name().String(), "refs/remotes/"), "/")
+		default:
+			return nil
+		}
+		// a branch tracked from several remotes is read from the first in name order
+		if prev, ok := byName[name]; ok && prev.Ref < ref.Name().String() {
+			return nil
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return fmt.Errorf("could not read commit of %s: %w", ref.Name(), err)
+		}
+		byName[name] = Branch{Name: name, Ref: ref.Name().String(), Time: commit.Committer.When.UnixMilli()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, 0, len(byName))
+	for _, branch := range byName {
+		branches = append(branches, branch)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+	return branches, nil
+}
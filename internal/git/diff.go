@@ -0,0 +1,172 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/search"
+)
+
+// FindReferenceChanges compares the head commit against the merge base of baseRef and head, and returns the code
+// references on lines added and removed by the change. Only changed files are read. Uncommitted changes in the
+// working tree are not compared, since both sides are read from commits.
+func (c Client) FindReferenceChanges(ctx context.Context, baseRef string, matcher search.Matcher) (gb.DiffRep, error) {
+	repo, err := git.PlainOpen(c.workspace)
+	if err != nil {
+		return gb.DiffRep{}, err
+	}
+
+	headCommit, err := c.headCommit(repo)
+	if err != nil {
+		return gb.DiffRep{}, err
+	}
+	baseCommit, err := resolveMergeBase(repo, baseRef, headCommit)
+	if err != nil {
+		return gb.DiffRep{}, err
+	}
+	log.Info.Printf("comparing %s against base commit %s", headCommit.Hash, baseCommit.Hash)
+
+	patch, err := baseCommit.PatchContext(ctx, headCommit)
+	if err != nil {
+		return gb.DiffRep{}, err
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return gb.DiffRep{}, err
+	}
+	isIgnored, err := c.ignoredPaths(headTree)
+	if err != nil {
+		return gb.DiffRep{}, err
+	}
+	ret := gb.DiffRep{
+		Base:         baseCommit.Hash.String(),
+		Head:         headCommit.Hash.String(),
+		ChangedFiles: []string{},
+		Added:        []gb.ReferenceChangeRep{},
+		Removed:      []gb.ReferenceChangeRep{},
+	}
+	delta := map[[2]string]int{}
+	for _, filePatch := range patch.FilePatches() {
+		fromPath, toPath := filePatchPaths(filePatch)
+		path := toPath
+		if path == "" {
+			path = fromPath
+		}
+		if filePatch.IsBinary() || isIgnored(path) {
+			continue
+		}
+		ret.ChangedFiles = append(ret.ChangedFiles, path)
+
+		oldLineNum, newLineNum := 1, 1
+		for _, chunk := range filePatch.Chunks() {
+			lines := chunkLines(chunk)
+			switch chunk.Type() {
+			case diff.Equal:
+				oldLineNum += len(lines)
+				newLineNum += len(lines)
+			case diff.Add:
+				for i, line := range lines {
+					changes := findLineReferences(matcher, toPath, newLineNum+i, line)
+					ret.Added = append(ret.Added, changes...)
+					for _, change := range changes {
+						delta[[2]string{change.ProjKey, change.FlagKey}]++
+					}
+				}
+				newLineNum += len(lines)
+			case diff.Delete:
+				for i, line := range lines {
+					changes := findLineReferences(matcher, fromPath, oldLineNum+i, line)
+					ret.Removed = append(ret.Removed, changes...)
+					for _, change := range changes {
+						delta[[2]string{change.ProjKey, change.FlagKey}]--
+					}
+				}
+				oldLineNum += len(lines)
+			}
+		}
+	}
+
+	ret.Delta = make([]gb.ReferenceDeltaRep, 0, len(delta))
+	for key, n := range delta {
+		ret.Delta = append(ret.Delta, gb.ReferenceDeltaRep{ProjKey: key[0], FlagKey: key[1], Delta: n})
+	}
+	sort.Slice(ret.Delta, func(i, j int) bool {
+		if ret.Delta[i].ProjKey != ret.Delta[j].ProjKey {
+			return ret.Delta[i].ProjKey < ret.Delta[j].ProjKey
+		}
+		return ret.Delta[i].FlagKey < ret.Delta[j].FlagKey
+	})
+	return ret, nil
+}
+
+// resolveMergeBase returns the merge base of baseRef and head, falling back to baseRef itself when the histories are unrelated
+func resolveMergeBase(repo *git.Repository, baseRef string, head *object.Commit) (*object.Commit, error) {
+	baseHash, err := repo.ResolveRevision(plumbing.Revision(baseRef))
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve base ref %q: %w", baseRef, err)
+	}
+	base, err := repo.CommitObject(*baseHash)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeBases, err := base.MergeBase(head)
+	if err != nil {
+		return nil, err
+	}
+	if len(mergeBases) > 0 {
+		base = mergeBases[0]
+	}
+	return base, nil
+}
+
+// findLineReferences returns a change for each flag referenced on a line, using the matchers of the projects containing path
+func findLineReferences(matcher search.Matcher, path string, lineNum int, line string) []gb.ReferenceChangeRep {
+	ret := []gb.ReferenceChangeRep{}
+	for _, elementMatcher := range matcher.Elements {
+		if !elementMatcher.MatchesPath(path) {
+			continue
+		}
+		for _, flagKey := range elementMatcher.FindMatches(line) {
+			ret = append(ret, gb.ReferenceChangeRep{
+				FilePath:   path,
+				LineNumber: lineNum,
+				Line:       search.TruncateLine(line),
+				FlagKey:    flagKey,
+				Aliases:    elementMatcher.FindAliases(line, flagKey),
+				ProjKey:    elementMatcher.ProjKey,
+			})
+		}
+	}
+	return ret
+}
+
+func filePatchPaths(filePatch diff.FilePatch) (fromPath, toPath string) {
+	fromFile, toFile := filePatch.Files()
+	if fromFile != nil {
+		fromPath = fromFile.Path()
+	}
+	if toFile != nil {
+		toPath = toFile.Path()
+	}
+	return fromPath, toPath
+}
+
+// chunkLines splits the content of a chunk into lines, ignoring the final line break
+func chunkLines(chunk diff.Chunk) []string {
+	content := strings.TrimSuffix(chunk.Content(), "\n")
+	if content == "" && chunk.Content() == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
@@ -7,14 +7,18 @@ import (
 	"io"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/diff"
 	object "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 
 	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/helpers"
 	"github.com/growthbook/gb-find-code-refs/search"
 
 	"github.com/growthbook/gb-find-code-refs/internal/log"
@@ -22,18 +26,18 @@ import (
 
 type Client struct {
 	workspace    string
+	ref          plumbing.Hash // the commit read in ref mode, zero for the checked out commit
 	GitBranch    string
 	GitSha       string
 	GitTimestamp int64
 }
 
 func NewClient(path string, branch string, allowTags bool) (*Client, error) {
+	client := Client{workspace: path}
 	if !filepath.IsAbs(path) {
-		log.Error.Fatalf("expected an absolute path but received a relative path: %s", path)
+		return &client, fmt.Errorf("expected an absolute path but received a relative path: %s", path)
 	}
 
-	client := Client{workspace: path}
-
 	_, err := exec.LookPath("git")
 	if err != nil {
 		return &client, errors.New("git is a required dependency, but was not found in the system PATH")
@@ -186,6 +190,8 @@ func (c *Client) commitTime() (commitTime int64, err error) {
 	return commitTime, nil
 }
 
+// RemoteBranches lists the branches currently on each remote of the repository, which requires access to the
+// remotes. The checked out branch, if any, is always included.
 func (c *Client) RemoteBranches() (branches map[string]bool, err error) {
 	branches = map[string]bool{}
 	repo, err := git.PlainOpen(c.workspace)
@@ -216,77 +222,231 @@ func (c *Client) RemoteBranches() (branches map[string]bool, err error) {
 	}
 
 	// the current branch should be in the list of remote branches
-	branches[c.GitBranch] = true
+	if c.GitBranch != "" {
+		branches[c.GitBranch] = true
+	}
 	return branches, nil
 }
 
-type CommitData struct {
-	commit *object.Commit
-	tree   *object.Tree
+// HistoryOptions bounds the commits searched in history
+type HistoryOptions struct {
+	// Lookback is the maximum number of commits to read, starting with the checked out commit or configured ref. If 0, all commits are read.
+	Lookback int
+
+	// Since excludes commits made before this time when it is not zero
+	Since time.Time
+
+	// Until excludes commits made after this time when it is not zero
+	Until time.Time
+
+	// FirstParent follows only the first parent of merge commits, so that only the commits of the checked out
+	// branch are read
+	FirstParent bool
 }
 
-// FindExtinctions searches commit history for flags that had references removed recently
-func (c Client) FindExtinctions(flags []string, matcher search.Matcher, lookback int) ([]gb.ExtinctionRep, error) {
-	commits, err := getCommits(c.workspace, lookback)
+// FindExtinctions searches commit history for flags that had references removed recently. Flags are keyed by project,
+// and all projects are searched in a single pass over the commit history. Each commit is compared against its first
+// parent, so removals are attributed to the commit that made them even if its parent is outside the searched history.
+// The most recent removal of each flag is returned.
+func (c Client) FindExtinctions(ctx context.Context, flagsByProject map[string][]string, matcher search.Matcher, history HistoryOptions) ([]gb.ExtinctionRep, error) {
+	elementMatchers, err := projectElementMatchers(flagsByProject, matcher)
 	if err != nil {
 		return nil, err
 	}
+	remainingFlagsByProject := make(map[string][]string, len(flagsByProject))
+	for projKey, flags := range flagsByProject {
+		remainingFlagsByProject[projKey] = flags
+	}
 
-	// get matcher for project
-	elementMatcher := matcher.GetElementMatcher()
-	if elementMatcher == nil {
-		// This is actually a huge issue if it happens
-		panic(fmt.Sprintf("Matcher for project (%s) not found", "default"))
+	repo, err := git.PlainOpen(c.workspace)
+	if err != nil {
+		return nil, err
+	}
+	head, err := c.headCommit(repo)
+	if err != nil {
+		return nil, err
 	}
 
 	ret := []gb.ExtinctionRep{}
-	for i, c := range commits[:len(commits)-1] {
-		log.Debug.Printf("Examining commit: %s", c.commit.Hash)
-		changes, err := commits[i+1].tree.Diff(c.tree)
-		if err != nil {
-			return nil, err
+	err = forEachCommit(head, history, func(commit *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		patch, err := changes.PatchContext(context.Background())
+		log.Debug.Printf("Examining commit: %s", commit.Hash)
+		patch, err := commitPatch(ctx, commit)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		nextFlags := make([]string, 0, len(flags))
-		flagMap := getFlagDeltaMap(flags)
+		flagMapByProject := make(map[string]map[string]int, len(remainingFlagsByProject))
+		for projKey, flags := range remainingFlagsByProject {
+			flagMapByProject[projKey] = getFlagDeltaMap(flags)
+		}
 
 		for _, filePatch := range patch.FilePatches() {
-			if !shouldScanFilePatch("", filePatch) {
-				continue
+			for projKey, elementMatcher := range elementMatchers {
+				if !shouldScanFilePatch(elementMatcher.Dir, filePatch) {
+					continue
+				}
+				flagMap := flagMapByProject[projKey]
+
+				for _, chunk := range filePatch.Chunks() {
+					delta := getDeltaFromChunkType(chunk.Type())
+					if delta == 0 {
+						continue
+					}
+					for _, line := range strings.Split(chunk.Content(), "\n") {
+						for _, el := range elementMatcher.FindMatches(line) {
+							if _, ok := flagMap[el]; ok {
+								flagMap[el] += delta
+							}
+						}
+					}
+				}
 			}
+		}
+
+		for projKey, flagMap := range flagMapByProject {
+			nextFlags := make([]string, 0, len(flagMap))
+			for flag, removalCount := range flagMap {
+				if removalCount > 0 {
+					ret = append(ret, makeExtinctionRepFromCommit(projKey, flag, commit))
+					log.Debug.Printf("Found extinct flag: %s in project: %q", flag, projKey)
+				} else {
+					// this flag was not removed in the current commit, so check for it again in the next commit
+					nextFlags = append(nextFlags, flag)
+				}
+			}
+			remainingFlagsByProject[projKey] = nextFlags
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// FindIntroductions searches commit history for the commit that first added a reference to each flag. Each commit is
+// compared against its first parent, and the root commit against an empty tree, so the earliest commit adding a
+// reference is found regardless of the order commits are read in.
+func (c Client) FindIntroductions(ctx context.Context, flagsByProject map[string][]string, matcher search.Matcher, history HistoryOptions) ([]gb.IntroductionRep, error) {
+	elementMatchers, err := projectElementMatchers(flagsByProject, matcher)
+	if err != nil {
+		return nil, err
+	}
+	wantedFlagsByProject := make(map[string]map[string]bool, len(flagsByProject))
+	for projKey, flags := range flagsByProject {
+		wantedFlagsByProject[projKey] = make(map[string]bool, len(flags))
+		for _, flag := range flags {
+			wantedFlagsByProject[projKey][flag] = true
+		}
+	}
+
+	repo, err := git.PlainOpen(c.workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := c.headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	introductions := map[[2]string]*object.Commit{}
+	err = forEachCommit(head, history, func(commit *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Debug.Printf("Examining commit: %s", commit.Hash)
 
-			for _, chunk := range filePatch.Chunks() {
-				delta := getDeltaFromChunkType(chunk.Type())
-				if delta == 0 {
+		patch, err := commitPatch(ctx, commit)
+		if err != nil {
+			return err
+		}
+		for _, filePatch := range patch.FilePatches() {
+			for projKey, elementMatcher := range elementMatchers {
+				if !shouldScanFilePatch(elementMatcher.Dir, filePatch) {
 					continue
 				}
-				for _, line := range strings.Split(chunk.Content(), "\n") {
-					for _, el := range elementMatcher.FindMatches(line) {
-						if _, ok := flagMap[el]; ok {
-							flagMap[el] += delta
+				for _, chunk := range filePatch.Chunks() {
+					if chunk.Type() != diff.Add {
+						continue
+					}
+					for _, line := range strings.Split(chunk.Content(), "\n") {
+						for _, flag := range elementMatcher.FindMatches(line) {
+							if !wantedFlagsByProject[projKey][flag] {
+								continue
+							}
+							key := [2]string{projKey, flag}
+							if prev, ok := introductions[key]; !ok || !commit.Author.When.After(prev.Author.When) {
+								introductions[key] = commit
+							}
 						}
 					}
 				}
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]gb.IntroductionRep, 0, len(introductions))
+	for key, commit := range introductions {
+		ret = append(ret, gb.IntroductionRep{
+			Revision: commit.Hash.String(),
+			Message:  commit.Message,
+			Time:     commit.Author.When.Unix() * 1000,
+			Author:   commit.Author.Name,
+			FlagKey:  key[1],
+			ProjKey:  key[0],
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].ProjKey != ret[j].ProjKey {
+			return ret[i].ProjKey < ret[j].ProjKey
+		}
+		return ret[i].FlagKey < ret[j].FlagKey
+	})
+	return ret, nil
+}
 
-		for flag, removalCount := range flagMap {
-			if removalCount > 0 {
-				ret = append(ret, makeExtinctionRepFromCommit(flag, c.commit))
-				log.Debug.Printf("Found extinct flag: %s in project: %s", flag, "default")
-			} else {
-				// this flag was not removed in the current commit, so check for it again in the next commit
-				nextFlags = append(nextFlags, flag)
-			}
+// commitPatch returns the changes made by a commit relative to its first parent, or all of its files if it is a root commit
+func commitPatch(ctx context.Context, commit *object.Commit) (*object.Patch, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
 		}
-		flags = nextFlags
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, parentTree, tree, nil)
+	if err != nil {
+		return nil, err
 	}
+	return changes.PatchContext(ctx)
+}
 
-	return ret, err
+// projectElementMatchers returns the element matcher of each project in flagsByProject
+func projectElementMatchers(flagsByProject map[string][]string, matcher search.Matcher) (map[string]*search.ElementMatcher, error) {
+	elementMatchers := make(map[string]*search.ElementMatcher, len(flagsByProject))
+	for projKey := range flagsByProject {
+		elementMatcher := matcher.GetProjectElementMatcher(projKey)
+		if elementMatcher == nil {
+			return nil, fmt.Errorf("matcher for project %q not found", projKey)
+		}
+		elementMatchers[projKey] = elementMatcher
+	}
+	return elementMatchers, nil
 }
 
 // Determine if changed file should be scanned
@@ -300,11 +460,11 @@ func shouldScanFilePatch(projectDir string, filePatch diff.FilePatch) bool {
 
 	// Ignore files outside of the project directory
 
-	if toFile != nil && strings.HasPrefix(toFile.Path(), projectDir) {
+	if toFile != nil && helpers.IsInDir(toFile.Path(), projectDir) {
 		return true
 	}
 
-	if fromFile != nil && strings.HasPrefix(fromFile.Path(), projectDir) {
+	if fromFile != nil && helpers.IsInDir(fromFile.Path(), projectDir) {
 		return true
 	}
 
@@ -322,43 +482,103 @@ func printDebugStatement(fromFile, toFile diff.File) {
 	log.Debug.Printf("Scanning from file: %s and to file: %s", fromPath, toPath)
 }
 
-func makeExtinctionRepFromCommit(flagKey string, commit *object.Commit) gb.ExtinctionRep {
+func makeExtinctionRepFromCommit(projKey, flagKey string, commit *object.Commit) gb.ExtinctionRep {
 	return gb.ExtinctionRep{
 		Revision: commit.Hash.String(),
 		Message:  commit.Message,
 		Time:     commit.Author.When.Unix() * 1000,
+		Author:   commit.Author.Name,
 		FlagKey:  flagKey,
+		ProjKey:  projKey,
 	}
 }
 
-func getCommits(workspace string, lookback int) ([]CommitData, error) {
-	repo, err := git.PlainOpen(workspace)
-	if err != nil {
-		return nil, err
-	}
-	logResult, err := repo.Log(&git.LogOptions{})
-	if err != nil {
-		return nil, err
+// maxCommitsBeforeSince is the number of consecutive first parents made before the start of history after which
+// reading history stops
+const maxCommitsBeforeSince = 100
+
+// forEachCommit calls fn for each commit within history, starting with commit. Commits are read in log order, or
+// by following first parents only if history.FirstParent is set. In log order, all commits are read even if
+// history.Since is set, since the commits of merged branches may be older than the commits that follow them.
+func forEachCommit(commit *object.Commit, history HistoryOptions, fn func(*object.Commit) error) error {
+	var commits object.CommitIter
+	if history.FirstParent {
+		commits = &firstParentIter{next: commit}
+	} else {
+		commits = object.NewCommitPreorderIter(commit, nil, nil)
+	}
+	defer commits.Close()
+
+	count, olderRun := 0, 0
+	err := commits.ForEach(func(commit *object.Commit) error {
+		// commits are reported with their author time, so the same time is used to select them. Author times may be
+		// out of order, e.g. after a rebase, so reading continues past a few commits made before since.
+		when := commit.Author.When
+		if !history.Since.IsZero() && when.Before(history.Since) {
+			olderRun++
+			if history.FirstParent && olderRun >= maxCommitsBeforeSince {
+				// first parents are older than their children, so no later commit is likely to be within history
+				return storer.ErrStop
+			}
+			return nil
+		}
+		olderRun = 0
+		if !history.Until.IsZero() && when.After(history.Until) {
+			return nil
+		}
+		if history.Lookback > 0 && count >= history.Lookback {
+			return storer.ErrStop
+		}
+		count++
+		return fn(commit)
+	})
+	if errors.Is(err, storer.ErrStop) {
+		return nil
 	}
+	return err
+}
 
-	commits := []CommitData{}
-	for i := 0; i < lookback; i++ {
-		commit, err := logResult.Next()
+// firstParentIter iterates over a commit and its chain of first parents
+type firstParentIter struct {
+	next *object.Commit
+}
+
+func (it *firstParentIter) Next() (*object.Commit, error) {
+	commit := it.next
+	if commit == nil {
+		return nil, io.EOF
+	}
+	it.next = nil
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
 		if err != nil {
-			// reached end of commit tree
-			if err == io.EOF {
-				break
-			}
 			return nil, err
 		}
-		tree, err := commit.Tree()
+		it.next = parent
+	}
+	return commit, nil
+}
+
+func (it *firstParentIter) ForEach(cb func(*object.Commit) error) error {
+	for {
+		commit, err := it.Next()
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := cb(commit); err != nil {
+			if err == storer.ErrStop {
+				return nil
+			}
+			return err
 		}
-		commits = append(commits, CommitData{commit, tree})
 	}
+}
 
-	return commits, nil
+func (it *firstParentIter) Close() {
+	it.next = nil
 }
 
 func getFlagDeltaMap(flags []string) map[string]int {
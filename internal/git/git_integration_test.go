@@ -1,12 +1,17 @@
 package git
 
 import (
+	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/require"
 
@@ -84,12 +89,12 @@ func TestFindExtinctions(t *testing.T) {
 	missingFlags := []string{flag1, flag2}
 	matcher := search.Matcher{
 		Elements: []search.ElementMatcher{
-			search.NewElementMatcher(``, ``, []string{flag1, flag2}, nil),
+			search.NewElementMatcher("", ``, ``, []string{flag1, flag2}, nil),
 		},
 	}
 
 	extinctions := make([]gb.ExtinctionRep, 0)
-	extinctionsByProject, err := c.FindExtinctions(missingFlags, matcher, 10)
+	extinctionsByProject, err := c.FindExtinctions(context.Background(), map[string][]string{"": missingFlags}, matcher, HistoryOptions{Lookback: 10})
 	require.NoError(t, err)
 	extinctions = append(extinctions, extinctionsByProject...)
 
@@ -98,15 +103,410 @@ func TestFindExtinctions(t *testing.T) {
 			Revision: commit3.String(),
 			Message:  message3,
 			Time:     who.When.Unix() * 1000,
+			Author:   who.Name,
 			FlagKey:  flag2,
 		},
 		{
 			Revision: commit2.String(),
 			Message:  message2,
 			Time:     who.When.Add(-time.Minute).Unix() * 1000,
+			Author:   who.Name,
 			FlagKey:  flag1,
 		},
 	}
 
 	require.Equal(t, expected, extinctions)
+
+	// commits made before since are not searched
+	extinctions, err = c.FindExtinctions(context.Background(), map[string][]string{"": missingFlags}, matcher, HistoryOptions{Lookback: 10, Since: who.When})
+	require.NoError(t, err)
+	require.Equal(t, expected[:1], extinctions)
+
+	// a commit is compared against its parent even if the parent is outside the time window
+	window := who.When.Add(-time.Minute)
+	extinctions, err = c.FindExtinctions(context.Background(), map[string][]string{"": missingFlags}, matcher, HistoryOptions{Lookback: 10, Since: window, Until: window})
+	require.NoError(t, err)
+	require.Equal(t, expected[1:], extinctions)
+}
+
+func TestFindExtinctions_AuthorTime(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	author := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	committer := author
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "flag1.txt"), []byte(flag1), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "README"), []byte("readme"), 0600))
+	require.NoError(t, wt.AddGlob("*"))
+	_, err = wt.Commit("add flag1", &git.CommitOptions{Author: &author, Committer: &committer})
+	require.NoError(t, err)
+
+	// a rebased commit is committed long after it was authored
+	require.NoError(t, os.Remove(filepath.Join(repoDir, "flag1.txt")))
+	author.When = author.When.Add(time.Minute)
+	committer.When = author.When.Add(time.Hour)
+	_, err = wt.Commit("remove flag1", &git.CommitOptions{All: true, Author: &author, Committer: &committer})
+	require.NoError(t, err)
+
+	c := Client{workspace: repoDir}
+	matcher := search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("", ``, ``, []string{flag1}, nil),
+		},
+	}
+	flags := map[string][]string{"": {flag1}}
+
+	extinctions, err := c.FindExtinctions(context.Background(), flags, matcher, HistoryOptions{Lookback: 10, Until: author.When})
+	require.NoError(t, err)
+	require.Len(t, extinctions, 1)
+	require.Equal(t, author.When.Unix()*1000, extinctions[0].Time)
+
+	extinctions, err = c.FindExtinctions(context.Background(), flags, matcher, HistoryOptions{Lookback: 10, Since: author.When.Add(time.Second)})
+	require.NoError(t, err)
+	require.Empty(t, extinctions)
+}
+
+func TestFindExtinctions_FirstParent(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	commit := func(message string, opts git.CommitOptions) plumbing.Hash {
+		who.When = who.When.Add(time.Minute)
+		opts.Author, opts.Committer = &who, &who
+		hash, err := wt.Commit(message, &opts)
+		require.NoError(t, err)
+		return hash
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "flag1.txt"), []byte(flag1), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "README"), []byte("readme"), 0600))
+	require.NoError(t, wt.AddGlob("*"))
+	root := commit("add flag1", git.CommitOptions{})
+
+	// remove flag1 on a side branch
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Hash: root}))
+	_, err = wt.Remove("flag1.txt")
+	require.NoError(t, err)
+	side := commit("remove flag1", git.CommitOptions{})
+
+	// unrelated change on the main branch, followed by a merge of the side branch
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("master")}))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "other.txt"), []byte("other"), 0600))
+	_, err = wt.Add("other.txt")
+	require.NoError(t, err)
+	trunk := commit("unrelated change", git.CommitOptions{})
+	_, err = wt.Remove("flag1.txt")
+	require.NoError(t, err)
+	merge := commit("merge side branch", git.CommitOptions{Parents: []plumbing.Hash{trunk, side}})
+
+	c := Client{workspace: repoDir}
+	matcher := search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("", ``, ``, []string{flag1}, nil),
+		},
+	}
+	flags := map[string][]string{"": {flag1}}
+
+	extinctions, err := c.FindExtinctions(context.Background(), flags, matcher, HistoryOptions{Lookback: 10, FirstParent: true})
+	require.NoError(t, err)
+	require.Len(t, extinctions, 1)
+	require.Equal(t, merge.String(), extinctions[0].Revision)
+
+	// the merge commit is excluded by until
+	extinctions, err = c.FindExtinctions(context.Background(), flags, matcher, HistoryOptions{Lookback: 10, FirstParent: true, Until: who.When.Add(-time.Second)})
+	require.NoError(t, err)
+	require.Empty(t, extinctions)
+}
+
+func TestForEachCommit_Since(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	commit := func(i int) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, "file.txt"), []byte(strconv.Itoa(i)), 0600))
+		_, err := wt.Add("file.txt")
+		require.NoError(t, err)
+		hash, err := wt.Commit(strconv.Itoa(i), &git.CommitOptions{Author: &who, Committer: &who})
+		require.NoError(t, err)
+		return hash
+	}
+
+	// a commit within history behind a long run of older commits, as after a rebase of old commits
+	since := who.When.Add(time.Hour)
+	who.When = since.Add(time.Minute)
+	hidden := commit(0)
+	who.When = since.Add(-time.Minute)
+	for i := 1; i <= maxCommitsBeforeSince; i++ {
+		commit(i)
+	}
+	who.When = since.Add(time.Minute)
+	head := commit(maxCommitsBeforeSince + 1)
+
+	headCommit, err := repo.CommitObject(head)
+	require.NoError(t, err)
+	visit := func(history HistoryOptions) []plumbing.Hash {
+		visited := []plumbing.Hash{}
+		require.NoError(t, forEachCommit(headCommit, history, func(c *object.Commit) error {
+			visited = append(visited, c.Hash)
+			return nil
+		}))
+		return visited
+	}
+	require.Equal(t, []plumbing.Hash{head}, visit(HistoryOptions{Since: since, FirstParent: true}))
+	require.Equal(t, []plumbing.Hash{head, hidden}, visit(HistoryOptions{Since: since}))
+}
+
+func TestFindIntroductions(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+
+	commit := func(name, content, message string) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0600))
+		_, err := wt.Add(name)
+		require.NoError(t, err)
+		who.When = who.When.Add(time.Minute)
+		hash, err := wt.Commit(message, &git.CommitOptions{Committer: &who, Author: &who})
+		require.NoError(t, err)
+		return hash
+	}
+
+	root := commit("a.txt", flag1+"\n", "add flag1")
+	second := commit("b.txt", flag2+"\n", "add flag2")
+	commit("c.txt", flag1+"\n"+flag2+"\n", "use flags again")
+
+	c := Client{workspace: repoDir}
+	matcher := search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("", ``, ``, []string{flag1, flag2, flag3}, nil),
+		},
+	}
+
+	introductions, err := c.FindIntroductions(context.Background(), map[string][]string{"": {flag1, flag2, flag3}}, matcher, HistoryOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []gb.IntroductionRep{
+		{Revision: root.String(), Message: "add flag1", Time: time.Unix(100000060, 0).Unix() * 1000, Author: who.Name, FlagKey: flag1},
+		{Revision: second.String(), Message: "add flag2", Time: time.Unix(100000120, 0).Unix() * 1000, Author: who.Name, FlagKey: flag2},
+	}, introductions)
+
+	// commits made before since are not searched
+	introductions, err = c.FindIntroductions(context.Background(), map[string][]string{"": {flag1}}, matcher, HistoryOptions{Since: who.When})
+	require.NoError(t, err)
+	require.Len(t, introductions, 1)
+	require.Equal(t, "use flags again", introductions[0].Message)
+}
+
+func TestFindReferenceHistory(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+
+	commit := func(name, content string) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0600))
+		_, err := wt.Add(name)
+		require.NoError(t, err)
+		who.When = who.When.Add(time.Minute)
+		hash, err := wt.Commit("update "+name, &git.CommitOptions{Committer: &who, Author: &who})
+		require.NoError(t, err)
+		return hash
+	}
+
+	first := commit("a.txt", flag1+"\n"+flag1+"\n")
+	second := commit("b.txt", flag2+"\n")
+	third := commit("a.txt", "x\n"+flag1+"\n")
+
+	c := Client{workspace: repoDir}
+	matcher := search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("", ``, ``, []string{flag1, flag2, flag3}, nil),
+		},
+	}
+	flags := map[string][]string{"": {flag1, flag2, flag3}}
+	point := func(hash plumbing.Hash, minutes int64, counts ...gb.HistoryCountRep) gb.HistoryPointRep {
+		p := gb.HistoryPointRep{Revision: hash.String(), Time: (100000000 + minutes*60) * 1000, Flags: counts}
+		for _, c := range counts {
+			p.References += c.References
+		}
+		return p
+	}
+
+	points, err := c.FindReferenceHistory(context.Background(), flags, matcher, HistoryOptions{}, 1)
+	require.NoError(t, err)
+	require.Equal(t, []gb.HistoryPointRep{
+		point(first, 1, gb.HistoryCountRep{FlagKey: flag1, References: 2}),
+		point(second, 2, gb.HistoryCountRep{FlagKey: flag1, References: 2}, gb.HistoryCountRep{FlagKey: flag2, References: 1}),
+		point(third, 3, gb.HistoryCountRep{FlagKey: flag1, References: 1}, gb.HistoryCountRep{FlagKey: flag2, References: 1}),
+	}, points)
+
+	// every other commit, starting with the checked out commit
+	points, err = c.FindReferenceHistory(context.Background(), flags, matcher, HistoryOptions{}, 2)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	require.Equal(t, first.String(), points[0].Revision)
+	require.Equal(t, third.String(), points[1].Revision)
+
+	// counts at commits before until are derived from the checked out commit
+	points, err = c.FindReferenceHistory(context.Background(), flags, matcher, HistoryOptions{Until: who.When.Add(-time.Second)}, 1)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	require.Equal(t, point(second, 2, gb.HistoryCountRep{FlagKey: flag1, References: 2}, gb.HistoryCountRep{FlagKey: flag2, References: 1}), points[1])
+}
+
+func TestBlameReferences(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	jane := object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Unix(100000000, 0)}
+	john := object.Signature{Name: "John", Email: "john@example.com", When: time.Unix(100003600, 0)}
+
+	writeFile := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte(content), 0600))
+		_, err := wt.Add("a.txt")
+		require.NoError(t, err)
+	}
+	writeFile("x\n" + flag1 + "\n" + flag2 + "\n")
+	first, err := wt.Commit("first", &git.CommitOptions{Committer: &jane, Author: &jane})
+	require.NoError(t, err)
+	writeFile("x\n" + flag1 + "\n" + flag2 + " changed\n")
+	second, err := wt.Commit("second", &git.CommitOptions{Committer: &john, Author: &john})
+	require.NoError(t, err)
+	// uncommitted lines are not attributed
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte("x\n"+flag1+"\n"+flag2+" changed\n"+flag3+"\n"), 0600))
+
+	refs := []gb.ReferenceHunksRep{{
+		Path: "a.txt",
+		Hunks: []gb.HunkRep{
+			{FlagKey: flag1, StartingLineNumber: 1, Lines: "x\n" + flag1 + "\n" + flag2 + " changed"},
+			{FlagKey: flag2, StartingLineNumber: 2, Lines: flag1 + "\n" + flag2 + " changed"},
+			{FlagKey: flag3, StartingLineNumber: 4, Lines: flag3},
+		},
+	}}
+	c := Client{workspace: repoDir}
+	require.NoError(t, c.BlameReferences(context.Background(), refs))
+
+	hunks := refs[0].Hunks
+	require.Equal(t, &gb.BlameRep{Revision: first.String(), Author: "Jane", Email: "jane@example.com", Time: 100000000000}, hunks[0].Blame)
+	require.Equal(t, &gb.BlameRep{Revision: second.String(), Author: "John", Email: "john@example.com", Time: 100003600000}, hunks[1].Blame)
+	require.Nil(t, hunks[2].Blame)
+}
+
+func TestFindReferenceChanges(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+
+	writeFile := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0600))
+		_, err := wt.Add(name)
+		require.NoError(t, err)
+	}
+
+	writeFile("a.txt", "x\n"+flag1+"\ny\n")
+	base, err := wt.Commit("base", &git.CommitOptions{Committer: &who, Author: &who})
+	require.NoError(t, err)
+
+	writeFile("a.txt", "x\ny\n"+flag2+"\n")
+	writeFile("b.txt", "first\n"+flag1+"\n")
+	_, err = wt.Commit("change", &git.CommitOptions{Committer: &who, Author: &who})
+	require.NoError(t, err)
+
+	c := Client{workspace: repoDir}
+	matcher := search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("", ``, ``, []string{flag1, flag2}, nil),
+		},
+	}
+
+	got, err := c.FindReferenceChanges(context.Background(), base.String(), matcher)
+	require.NoError(t, err)
+	require.Equal(t, base.String(), got.Base)
+	require.Equal(t, []string{"a.txt", "b.txt"}, got.ChangedFiles)
+	require.Equal(t, []gb.ReferenceChangeRep{
+		{FilePath: "a.txt", LineNumber: 3, Line: flag2, FlagKey: flag2, Aliases: []string{}},
+		{FilePath: "b.txt", LineNumber: 2, Line: flag1, FlagKey: flag1, Aliases: []string{}},
+	}, got.Added)
+	require.Equal(t, []gb.ReferenceChangeRep{
+		{FilePath: "a.txt", LineNumber: 2, Line: flag1, FlagKey: flag1, Aliases: []string{}},
+	}, got.Removed)
+	require.Equal(t, []gb.ReferenceDeltaRep{{FlagKey: flag1, Delta: 0}, {FlagKey: flag2, Delta: 1}}, got.Delta)
+
+	// the changes to a flag are counted separately in each project
+	writeFile("b.txt", "first\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "web"), 0700))
+	writeFile("web/c.txt", flag1+"\n")
+	_, err = wt.Commit("projects", &git.CommitOptions{Committer: &who, Author: &who})
+	require.NoError(t, err)
+	matcher = search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("api", "", ``, []string{flag1}, nil),
+			search.NewElementMatcher("web", "web", ``, []string{flag1}, nil),
+		},
+	}
+	got, err = c.FindReferenceChanges(context.Background(), "HEAD~1", matcher)
+	require.NoError(t, err)
+	require.Equal(t, []gb.ReferenceDeltaRep{{FlagKey: flag1, ProjKey: "api", Delta: 0}, {FlagKey: flag1, ProjKey: "web", Delta: 1}}, got.Delta)
+}
+
+func TestNewRefClient(t *testing.T) {
+	repo := setupRepo(t)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte(flag1+"\n"), 0600))
+	_, err = wt.Add("a.txt")
+	require.NoError(t, err)
+	first, err := wt.Commit("first", &git.CommitOptions{Committer: &who, Author: &who})
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/feature/x", first)))
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte(flag2+"\n"), 0600))
+	who.When = who.When.Add(time.Minute)
+	_, err = wt.Commit("second", &git.CommitOptions{All: true, Committer: &who, Author: &who})
+	require.NoError(t, err)
+
+	absRepoDir, err := filepath.Abs(repoDir)
+	require.NoError(t, err)
+	for ref, branch := range map[string]string{
+		"refs/remotes/origin/feature/x": "feature/x",
+		"origin/feature/x":              "feature/x",
+		first.String():                  first.String(),
+	} {
+		c, err := NewRefClient(absRepoDir, ref, "")
+		require.NoError(t, err)
+		require.Equal(t, branch, c.GitBranch)
+		require.Equal(t, first.String(), c.GitSha)
+		require.Equal(t, int64(100000000000), c.GitTimestamp)
+	}
+
+	c, err := NewRefClient(absRepoDir, "origin/feature/x", "main")
+	require.NoError(t, err)
+	require.Equal(t, "main", c.GitBranch)
+
+	// the commit of the ref is searched rather than the checked out one
+	matcher := search.Matcher{
+		Elements: []search.ElementMatcher{
+			search.NewElementMatcher("", ``, ``, []string{flag1, flag2}, nil),
+		},
+	}
+	refs, err := c.SearchHeadForRefs(context.Background(), matcher)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, flag1, refs[0].Hunks[0].FlagKey)
+
+	files, err := c.HeadFS()
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(files, "a.txt"))
+	data, err := fs.ReadFile(files, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, flag1+"\n", string(data))
+
+	_, err = NewRefClient(absRepoDir, "missing", "")
+	require.Error(t, err)
 }
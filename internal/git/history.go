@@ -0,0 +1,183 @@
+package git
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/search"
+)
+
+type projectFlag struct {
+	projKey string
+	flagKey string
+}
+
+// FindReferenceHistory returns the number of lines referencing each flag at every interval-th commit within history,
+// oldest first. Only the tree of the head commit is searched in full. The counts of older commits are derived from the
+// lines added and removed between consecutive commits.
+func (c Client) FindReferenceHistory(ctx context.Context, flagsByProject map[string][]string, matcher search.Matcher, history HistoryOptions, interval int) ([]gb.HistoryPointRep, error) {
+	if interval < 1 {
+		interval = 1
+	}
+	elementMatchers, err := projectElementMatchers(flagsByProject, matcher)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[projectFlag]bool{}
+	for projKey, flags := range flagsByProject {
+		for _, flag := range flags {
+			wanted[projectFlag{projKey, flag}] = true
+		}
+	}
+
+	repo, err := git.PlainOpen(c.workspace)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := c.headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+	prevTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	isIgnored, err := c.ignoredPaths(prevTree)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := countTreeReferences(prevTree, elementMatchers, wanted, isIgnored)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []gb.HistoryPointRep{}
+	i := 0
+	err = forEachCommit(headCommit, history, func(commit *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Debug.Printf("Examining commit: %s", commit.Hash)
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+		if tree.Hash != prevTree.Hash {
+			changes, err := object.DiffTreeWithOptions(ctx, tree, prevTree, nil)
+			if err != nil {
+				return err
+			}
+			patch, err := changes.PatchContext(ctx)
+			if err != nil {
+				return err
+			}
+			// lines added since this commit did not exist yet, and lines removed since did
+			for _, filePatch := range patch.FilePatches() {
+				fromPath, toPath := filePatchPaths(filePatch)
+				path := toPath
+				if path == "" {
+					path = fromPath
+				}
+				if filePatch.IsBinary() || isIgnored(path) {
+					continue
+				}
+				for projKey, elementMatcher := range elementMatchers {
+					if !shouldScanFilePatch(elementMatcher.Dir, filePatch) {
+						continue
+					}
+					for _, chunk := range filePatch.Chunks() {
+						delta := getDeltaFromChunkType(chunk.Type())
+						if delta == 0 {
+							continue
+						}
+						for _, line := range strings.Split(chunk.Content(), "\n") {
+							for _, flag := range elementMatcher.FindMatches(line) {
+								if key := (projectFlag{projKey, flag}); wanted[key] {
+									counts[key] += delta
+								}
+							}
+						}
+					}
+				}
+			}
+			prevTree = tree
+		}
+
+		if i%interval == 0 {
+			points = append(points, makeHistoryPoint(commit, counts))
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// commits are read newest first
+	for l, r := 0, len(points)-1; l < r; l, r = l+1, r-1 {
+		points[l], points[r] = points[r], points[l]
+	}
+	return points, nil
+}
+
+// countTreeReferences counts the lines referencing each wanted flag in the files of a tree
+func countTreeReferences(tree *object.Tree, elementMatchers map[string]*search.ElementMatcher, wanted map[projectFlag]bool, isIgnored func(string) bool) (map[projectFlag]int, error) {
+	counts := map[projectFlag]int{}
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if isIgnored(f.Name) {
+			return nil
+		}
+		if binary, err := f.IsBinary(); err != nil || binary {
+			return err
+		}
+		lines, err := f.Lines()
+		if err != nil {
+			return err
+		}
+		for projKey, elementMatcher := range elementMatchers {
+			if !elementMatcher.MatchesPath(f.Name) {
+				continue
+			}
+			for _, line := range lines {
+				for _, flag := range elementMatcher.FindMatches(line) {
+					if key := (projectFlag{projKey, flag}); wanted[key] {
+						counts[key]++
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return counts, err
+}
+
+func makeHistoryPoint(commit *object.Commit, counts map[projectFlag]int) gb.HistoryPointRep {
+	point := gb.HistoryPointRep{
+		Revision: commit.Hash.String(),
+		Time:     commit.Author.When.Unix() * 1000,
+		Flags:    []gb.HistoryCountRep{},
+	}
+	for key, count := range counts {
+		if count <= 0 {
+			continue
+		}
+		point.References += count
+		point.Flags = append(point.Flags, gb.HistoryCountRep{FlagKey: key.flagKey, ProjKey: key.projKey, References: count})
+	}
+	sort.Slice(point.Flags, func(i, j int) bool {
+		a, b := point.Flags[i], point.Flags[j]
+		if a.ProjKey != b.ProjKey {
+			return a.ProjKey < b.ProjKey
+		}
+		return a.FlagKey < b.FlagKey
+	})
+	return point
+}
@@ -0,0 +1,134 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/log"
+	"github.com/growthbook/gb-find-code-refs/search"
+)
+
+// NewRefClient returns a client for the commit ref resolves to. The commit is read from the object database rather
+// than checked out, so path may be a bare repository. The branch name is taken from ref unless branch is set.
+func NewRefClient(path string, ref string, branch string) (*Client, error) {
+	client := Client{workspace: path}
+	if !filepath.IsAbs(path) {
+		return &client, fmt.Errorf("expected an absolute path but received a relative path: %s", path)
+	}
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return &client, err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return &client, fmt.Errorf("could not resolve ref %q: %w", ref, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return &client, fmt.Errorf("could not read commit of ref %q: %w", ref, err)
+	}
+
+	client.ref = commit.Hash
+	client.GitSha = commit.Hash.String()
+	client.GitTimestamp = commit.Author.When.UnixMilli()
+	client.GitBranch = branch
+	if branch == "" {
+		client.GitBranch = refBranchName(repo, ref)
+	}
+	log.Info.Printf("git ref %s: %s at %s", ref, client.GitBranch, client.GitSha)
+	return &client, nil
+}
+
+// refBranchName returns the name of the branch or tag ref points to, without the name of the remote for remote
+// branches. Other revisions are returned unchanged.
+func refBranchName(repo *git.Repository, ref string) string {
+	name := plumbing.ReferenceName(ref)
+	switch {
+	case name.IsBranch(), name.IsTag():
+		return name.Short()
+	case name.IsRemote():
+		ref = strings.TrimPrefix(ref, "refs/remotes/")
+	default:
+		// short names of remote branches, such as origin/main
+		if _, err := repo.Reference(plumbing.ReferenceName("refs/remotes/"+ref), false); err != nil {
+			return ref
+		}
+	}
+	// <remote>/<branch>
+	if _, branchName, ok := strings.Cut(ref, "/"); ok {
+		return branchName
+	}
+	return ref
+}
+
+// headCommit returns the commit of the configured ref, or the checked out commit
+func (c Client) headCommit(repo *git.Repository) (*object.Commit, error) {
+	hash := c.ref
+	if hash.IsZero() {
+		head, err := repo.Head()
+		if err != nil {
+			return nil, err
+		}
+		hash = head.Hash()
+	}
+	return repo.CommitObject(hash)
+}
+
+// SearchHeadForRefs returns the code references in the tree of the configured ref, or of the checked out commit.
+// Files are read from the object database, honoring the ignore files of the tree.
+func (c Client) SearchHeadForRefs(ctx context.Context, matcher search.Matcher) ([]gb.ReferenceHunksRep, error) {
+	tree, err := c.headTree()
+	if err != nil {
+		return nil, err
+	}
+	return search.SearchTreeForRefs(ctx, tree, matcher)
+}
+
+// OpenHeadFile opens a file of the tree of the configured ref, or of the checked out commit. The error wraps
+// fs.ErrNotExist if there is no such file.
+func (c Client) OpenHeadFile(path string) (io.ReadCloser, error) {
+	tree, err := c.headTree()
+	if err != nil {
+		return nil, err
+	}
+	f, err := tree.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f.Reader()
+}
+
+func (c Client) headTree() (*object.Tree, error) {
+	repo, err := git.PlainOpen(c.workspace)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := c.headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// ignoredPaths returns a function that reports whether a path is excluded from scanning. In ref mode the ignore
+// files are read from tree, otherwise from the workspace.
+func (c Client) ignoredPaths(tree *object.Tree) (func(path string) bool, error) {
+	if c.ref.IsZero() {
+		return search.IgnoredPaths(c.workspace), nil
+	}
+	return search.TreeIgnoredPaths(tree)
+}
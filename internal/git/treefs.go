@@ -0,0 +1,164 @@
+package git
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	object "github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// HeadFS returns the files of the tree of the configured ref, or of the checked out commit, as a read-only file
+// system
+func (c Client) HeadFS() (fs.FS, error) {
+	tree, err := c.headTree()
+	if err != nil {
+		return nil, err
+	}
+	return treeFS{tree: tree}, nil
+}
+
+// treeFS is a read-only fs.FS of the files of a git tree. Symlinks and submodules are not followed.
+type treeFS struct {
+	tree *object.Tree
+}
+
+func (t treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &treeDir{info: treeFileInfo{name: ".", mode: fs.ModeDir}, tree: t.tree}, nil
+	}
+	entry, err := t.tree.FindEntry(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info, err := entryInfo(t.tree, name, *entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if entry.Mode == filemode.Dir {
+		sub, err := t.tree.Tree(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &treeDir{info: info, tree: sub}, nil
+	}
+	if !isTreeFile(entry.Mode) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := t.tree.TreeEntryFile(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treeFile{ReadCloser: r, info: info}, nil
+}
+
+// isTreeFile reports whether a tree entry is a regular or executable file
+func isTreeFile(mode filemode.FileMode) bool {
+	return mode.IsFile() && mode != filemode.Symlink
+}
+
+// entryInfo returns the file info of the entry of tree at name
+func entryInfo(tree *object.Tree, name string, entry object.TreeEntry) (treeFileInfo, error) {
+	info := treeFileInfo{name: path.Base(name)}
+	switch {
+	case entry.Mode == filemode.Dir:
+		info.mode = fs.ModeDir
+	case isTreeFile(entry.Mode):
+		f, err := tree.TreeEntryFile(&entry)
+		if err != nil {
+			return info, err
+		}
+		info.size = f.Size
+	default:
+		info.mode = fs.ModeIrregular
+	}
+	return info, nil
+}
+
+type treeFile struct {
+	io.ReadCloser
+	info treeFileInfo
+}
+
+func (f *treeFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+type treeDir struct {
+	info   treeFileInfo
+	tree   *object.Tree
+	offset int
+}
+
+func (d *treeDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *treeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *treeDir) Close() error {
+	return nil
+}
+
+func (d *treeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.tree.Entries[d.offset:]
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	ret := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		ret = append(ret, treeDirEntry{tree: d.tree, entry: entry})
+		d.offset++
+	}
+	return ret, nil
+}
+
+// treeDirEntry is an entry of a tree. Its size is only read from the object database if its info is requested.
+type treeDirEntry struct {
+	tree  *object.Tree
+	entry object.TreeEntry
+}
+
+func (e treeDirEntry) Name() string { return e.entry.Name }
+func (e treeDirEntry) IsDir() bool  { return e.entry.Mode == filemode.Dir }
+
+func (e treeDirEntry) Type() fs.FileMode {
+	switch {
+	case e.IsDir():
+		return fs.ModeDir
+	case isTreeFile(e.entry.Mode):
+		return 0
+	}
+	return fs.ModeIrregular
+}
+
+func (e treeDirEntry) Info() (fs.FileInfo, error) {
+	return entryInfo(e.tree, e.entry.Name, e.entry)
+}
+
+type treeFileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (i treeFileInfo) Name() string       { return i.name }
+func (i treeFileInfo) Size() int64        { return i.size }
+func (i treeFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i treeFileInfo) ModTime() time.Time { return time.Time{} }
+func (i treeFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i treeFileInfo) Sys() interface{}   { return nil }
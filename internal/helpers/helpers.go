@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"strings"
 	"time"
 )
 
@@ -22,3 +23,12 @@ func Dedupe(s []string) []string {
 func MakeTimestamp() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
+
+// IsInDir reports whether a slash-separated relative path is dir or is contained within dir. An empty dir contains every path.
+func IsInDir(path, dir string) bool {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return true
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
@@ -14,6 +14,11 @@ var (
 	Error   *log.Logger
 )
 
+// the loggers are usable without Init, since library callers of coderefs cannot import this package
+func init() {
+	Init(false)
+}
+
 // Init overrides the default loggers that write to stdout
 func Init(debug bool) {
 	debugHandle := io.Discard
@@ -11,7 +11,7 @@ type AliasType string
 
 func (a AliasType) IsValid() error {
 	switch a.Canonical() {
-	case Literal, CamelCase, PascalCase, SnakeCase, UpperSnakeCase, KebabCase, DotCase, FilePattern, Command:
+	case Literal, CamelCase, PascalCase, SnakeCase, UpperSnakeCase, KebabCase, DotCase, FilePattern, Command, Regex, Transform, Structured, Constants:
 		return nil
 	}
 	return fmt.Errorf("'%s' is not a valid alias type", a)
@@ -25,6 +25,15 @@ func (a AliasType) Canonical() AliasType {
 	return AliasType(a.String())
 }
 
+// IsNamingConvention reports whether the alias type transposes flag keys to a casing convention
+func (a AliasType) IsNamingConvention() bool {
+	switch a.Canonical() {
+	case CamelCase, PascalCase, SnakeCase, UpperSnakeCase, KebabCase, DotCase:
+		return true
+	}
+	return false
+}
+
 func (a AliasType) unexpectedFieldErr(field string) error {
 	return fmt.Errorf("unexpected field for %s alias: '%s'", a, field)
 }
@@ -42,8 +51,78 @@ const (
 	FilePattern AliasType = "filepattern"
 
 	Command AliasType = "command"
+
+	Regex AliasType = "regex"
+
+	Transform AliasType = "transform"
+
+	Structured AliasType = "structured"
+
+	Constants AliasType = "constants"
 )
 
+// Steps of a transform alias pipeline, in addition to the naming convention alias types
+const (
+	LowercaseTransform      = "lowercase"
+	UppercaseTransform      = "uppercase"
+	StripNamespaceTransform = "stripnamespace"
+
+	DefaultNamespaceDelimiter = "."
+)
+
+// IsValidTransform reports whether step may be used in the transforms of an alias
+func IsValidTransform(step string) bool {
+	switch strings.ToLower(step) {
+	case LowercaseTransform, UppercaseTransform, StripNamespaceTransform:
+		return true
+	}
+	return AliasType(step).IsNamingConvention()
+}
+
+// regexPlaceholder matches the flag key placeholders of regex alias patterns: FLAG_KEY and {FLAG_KEY} stand for the
+// flag key, and {FLAG_KEY:<steps>} for the flag key transformed by a comma separated list of transform steps
+var regexPlaceholder = regexp.MustCompile(`\{FLAG_KEY(?::([^}]*))?\}|FLAG_KEY`)
+
+// ExpandRegexPattern replaces the flag key placeholders of a regex alias pattern with the quoted result of
+// transform, which is called with the transform steps of each placeholder. Step names are
+// normalized, so {FLAG_KEY:upper_snake} has the step uppersnakecase.
+func ExpandRegexPattern(pattern string, transform func(steps []string) (string, error)) (string, error) {
+	var err error
+	expanded := regexPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		if err != nil {
+			return ""
+		}
+		var steps []string
+		if match := regexPlaceholder.FindStringSubmatch(placeholder); match[1] != "" {
+			for _, name := range strings.Split(match[1], ",") {
+				step, ok := normalizeTransformStep(name)
+				if !ok {
+					err = fmt.Errorf("'%s' is not a valid transform of placeholder '%s'", name, placeholder)
+					return ""
+				}
+				steps = append(steps, step)
+			}
+		}
+		var key string
+		key, err = transform(steps)
+		return regexp.QuoteMeta(key)
+	})
+	return expanded, err
+}
+
+// normalizeTransformStep returns the transform step named by name, accepting short names of naming conventions
+// such as upper_snake or kebab-case
+func normalizeTransformStep(name string) (string, bool) {
+	step := strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(name))
+	if IsValidTransform(step) {
+		return step, true
+	}
+	if step += "case"; AliasType(step).IsNamingConvention() {
+		return step, true
+	}
+	return "", false
+}
+
 // Alias is a catch-all type for alias configurations
 type Alias struct {
 	Type AliasType `mapstructure:"type"`
@@ -52,13 +131,26 @@ type Alias struct {
 	// Literal
 	Flags map[string][]string `mapstructure:"flags,omitempty"`
 
-	// FilePattern
+	// FilePattern, Regex, Structured and Constants
 	Paths    []string `mapstructure:"paths,omitempty"`
 	Patterns []string `mapstructure:"patterns,omitempty"`
 
+	// Structured
+	Query string `mapstructure:"query,omitempty"`
+
 	// Command
 	Command *string `mapstructure:"command,omitempty"`
 	Timeout *int64  `mapstructure:"timeout,omitempty"`
+	// If set, the command runs once with all flag keys instead of once per flag key
+	Batch bool `mapstructure:"batch,omitempty"`
+
+	// Transform
+	Transforms         []string `mapstructure:"transforms,omitempty"`
+	NamespaceDelimiter string   `mapstructure:"namespaceDelimiter,omitempty"`
+
+	// Transform and naming conventions
+	Prefix string `mapstructure:"prefix,omitempty"`
+	Suffix string `mapstructure:"suffix,omitempty"`
 }
 
 func (a *Alias) IsValid() error {
@@ -86,6 +178,45 @@ func (a *Alias) IsValid() error {
 				return fmt.Errorf("could not validate regex pattern: %v", err)
 			}
 		}
+	case Regex:
+		if len(a.Patterns) == 0 {
+			return errors.New("regex aliases must provide at least one pattern in 'patterns'")
+		}
+		if len(a.Paths) > 0 {
+			return a.Type.unexpectedFieldErr("paths")
+		}
+		for _, pattern := range a.Patterns {
+			if !regexPlaceholder.MatchString(pattern) {
+				return fmt.Errorf("regex '%s' must contain 'FLAG_KEY' for templating", pattern)
+			}
+			expanded, err := ExpandRegexPattern(pattern, func([]string) (string, error) { return "flag", nil })
+			if err != nil {
+				return fmt.Errorf("could not validate regex pattern '%s': %v", pattern, err)
+			}
+			if _, err := regexp.Compile(expanded); err != nil {
+				return fmt.Errorf("could not validate regex pattern: %v", err)
+			}
+		}
+	case Structured:
+		if len(a.Paths) == 0 {
+			return errors.New("structured aliases must provide at least one path in 'paths'")
+		}
+		if _, err := ParseDataQuery(a.Query); err != nil {
+			return fmt.Errorf("could not validate query: %v", err)
+		}
+	case Constants:
+		if len(a.Paths) == 0 {
+			return errors.New("constants aliases must provide at least one path in 'paths'")
+		}
+	case Transform:
+		if len(a.Transforms) == 0 {
+			return errors.New("transform aliases must provide at least one transform in 'transforms'")
+		}
+		for _, step := range a.Transforms {
+			if !IsValidTransform(step) {
+				return fmt.Errorf("'%s' is not a valid transform", step)
+			}
+		}
 	case Command:
 		if a.Command == nil {
 			return errors.New("command aliases must provide a 'command'")
@@ -96,6 +227,29 @@ func (a *Alias) IsValid() error {
 	}
 
 	// Validate unexpected fields
+	if a.Type.Canonical() != Structured && a.Query != "" {
+		return a.Type.unexpectedFieldErr("query")
+	}
+	if a.Type.Canonical() != Command && a.Batch {
+		return a.Type.unexpectedFieldErr("batch")
+	}
+	if a.Type.Canonical() != Transform {
+		if len(a.Transforms) > 0 {
+			return a.Type.unexpectedFieldErr("transforms")
+		}
+		if a.NamespaceDelimiter != "" {
+			return a.Type.unexpectedFieldErr("namespaceDelimiter")
+		}
+		if !a.Type.IsNamingConvention() {
+			if a.Prefix != "" {
+				return a.Type.unexpectedFieldErr("prefix")
+			}
+			if a.Suffix != "" {
+				return a.Type.unexpectedFieldErr("suffix")
+			}
+		}
+	}
+
 	var unexpectedField string
 	switch {
 	case a.Type != Literal:
@@ -123,3 +277,60 @@ func (a *Alias) IsValid() error {
 
 	return nil
 }
+
+// DataQuery selects aliases from structured data files. Its syntax is `<path> == FLAG_KEY [-> <select>]`, where path
+// is a dot separated list of keys containing at least one `*` wildcard, e.g. `.features.* == FLAG_KEY`. Path is
+// matched against the document, and wherever the matched value equals the flag key, the key matched by the last
+// wildcard is an alias. If select is provided, the value at select relative to the node matched by the last
+// wildcard is the alias instead, e.g. `.flags.*.key == FLAG_KEY -> .constant`.
+type DataQuery struct {
+	Path   []string
+	Select []string
+}
+
+// WildcardIndex returns the index of the last wildcard in the path
+func (q DataQuery) WildcardIndex() int {
+	for i := len(q.Path) - 1; i >= 0; i-- {
+		if q.Path[i] == "*" {
+			return i
+		}
+	}
+	return -1
+}
+
+func ParseDataQuery(query string) (DataQuery, error) {
+	var q DataQuery
+	expr, selectExpr, hasSelect := strings.Cut(query, "->")
+	path, value, ok := strings.Cut(expr, "==")
+	if !ok || strings.TrimSpace(value) != "FLAG_KEY" {
+		return q, fmt.Errorf("query '%s' must have the form '<path> == FLAG_KEY'", query)
+	}
+
+	var err error
+	if q.Path, err = parseDataPath(path); err != nil {
+		return q, fmt.Errorf("query '%s': %w", query, err)
+	}
+	if q.WildcardIndex() < 0 {
+		return q, fmt.Errorf("query '%s': path must contain a '*' wildcard", query)
+	}
+	if hasSelect {
+		if q.Select, err = parseDataPath(selectExpr); err != nil {
+			return q, fmt.Errorf("query '%s': %w", query, err)
+		}
+	}
+	return q, nil
+}
+
+func parseDataPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, ".") || len(path) < 2 {
+		return nil, fmt.Errorf("path '%s' must start with '.' followed by a key", path)
+	}
+	keys := strings.Split(path[1:], ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("path '%s' contains an empty key", path)
+		}
+	}
+	return keys, nil
+}
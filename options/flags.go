@@ -9,11 +9,41 @@ type flag struct {
 
 // Options that are available as command line flags
 var flags = []flag{
+	{
+		name:         "aliasReport",
+		defaultValue: false,
+		usage: `Writes an alias_report JSON file listing aliases without references, aliases shared by several flags
+and aliases contained in common words.`,
+	},
+	{
+		name:         "apiHost",
+		defaultValue: "https://api.growthbook.io",
+		usage:        "Base URL of the GrowthBook API used to fetch flag keys when flagsPath is not provided.",
+	},
+	{
+		name:         "apiKey",
+		defaultValue: "",
+		usage: `GrowthBook API secret key. When provided and flagsPath is not set, flag keys
+will be fetched from the GrowthBook API. We recommend setting this with the GB_API_KEY environment variable.`,
+	},
 	{
 		name:         "allowTags",
 		defaultValue: false,
 		usage:        "Enables parsing references for tags.",
 	},
+	{
+		name:         "baseRef",
+		defaultValue: "",
+		usage: `If provided, only the changes between this git ref (e.g. a branch name or commit sha) and the currently
+checked out commit are scanned. References added and removed by the change are written to a separate
+coderefs_diff JSON file instead of scanning the whole repository.`,
+	},
+	{
+		name:         "blame",
+		defaultValue: false,
+		usage: `Records on each code reference the last commit, author and time that changed the referencing line, using
+git blame. Each file with references is blamed once.`,
+	},
 	{
 		name:         "branch",
 		short:        "b",
@@ -21,6 +51,18 @@ var flags = []flag{
 		usage: `The currently checked out branch. If not provided, branch
 name will be auto-detected. Provide this option when using CI systems that
 leave the repository in a detached HEAD state.`,
+	},
+	{
+		name:         "branchGlob",
+		defaultValue: []string{},
+		usage: `Glob patterns of the remote branches scanned by the branches command, e.g. "release/*" or "feature/**".
+May be repeated or comma separated. If not provided, all remote branches are scanned.`,
+	},
+	{
+		name:         "branchMaxAge",
+		defaultValue: "",
+		usage: `If provided, the branches command skips remote branches without commits since this time. May be a date
+(YYYY-MM-DD), an RFC 3339 timestamp or a duration before now such as "72h", "30d" or "12w".`,
 	},
 	{
 		name:         "contextLines",
@@ -39,12 +81,48 @@ leave the repository in a detached HEAD state.`,
 		defaultValue: "",
 		usage:        "Path to existing checkout of the repository.",
 	},
+	{
+		name:         "dryRun",
+		defaultValue: false,
+		usage:        `If enabled with "upload", prints the requests that would be sent to the GrowthBook API instead of sending them.`,
+	},
+	{
+		name:         "failOnAliasCollision",
+		defaultValue: false,
+		usage:        "Fails the scan with a non-zero exit code when the same alias is generated for more than one flag.",
+	},
+	{
+		name:         "firstParent",
+		defaultValue: false,
+		usage: `Follows only the first parent of merge commits when searching git history, so that only the commits of the
+checked out branch are searched.`,
+	},
+	{
+		name:         "format",
+		defaultValue: []string{"json"},
+		usage: `Formats of the code references output files. May be repeated or comma separated to write several files in one run.
+"json" writes the GrowthBook code references document, "sarif" writes a SARIF 2.1.0 log for code scanning tools,
+"csv" writes one row per reference, "ndjson" writes one JSON reference per line and "markdown" writes a summary report.`,
+	},
+	{
+		name:         "historyInterval",
+		defaultValue: 1,
+		usage: `The history command counts references at every commit when set to 0 or 1, or at every N-th commit
+when set to N.`,
+	},
+	{
+		name:         "introductions",
+		defaultValue: false,
+		usage: `Searches git history for the commit that first added a reference to each flag with references, and writes
+them as introductions alongside extinctions. The whole history is searched unless "since" is set.`,
+	},
 	{
 		name:         "lookback",
 		short:        "l",
 		defaultValue: 10,
 		usage: `Sets the number of git commits to search in history for
-whether a feature flag was removed from code. May be set to 0 to disabled this feature. Setting this option to a high value will increase search time.`,
+whether a feature flag was removed from code. May be set to 0 to disabled this feature. Setting this option to a high value will increase search time.
+Combined with "since" and "until", at most this number of commits within the time window are searched.`,
 	},
 	{
 		name:         "outDir",
@@ -52,6 +130,14 @@ whether a feature flag was removed from code. May be set to 0 to disabled this f
 		defaultValue: "",
 		usage:        `If provided, will output the JSON file containing all code references to this directory. Otherwise, will output JSON file to current working directory.`,
 	},
+	{
+		name:         "ref",
+		defaultValue: "",
+		usage: `If provided, the files of the commit this git ref (e.g. a branch name, remote branch or commit sha) points to
+are scanned from the git object database instead of the working tree, so nothing needs to be checked out and
+"dir" may be a bare repository. Ignore files are read from the commit. The branch name is taken from the ref
+unless "branch" is set.`,
+	},
 	{
 		name:         "revision",
 		short:        "R",
@@ -62,7 +148,7 @@ whether a feature flag was removed from code. May be set to 0 to disabled this f
 		name:         "flagsPath",
 		short:        "f",
 		defaultValue: "",
-		usage:        "Required path to a JSON file containing a list of flag keys (array of strings). The scanner will search for references to the flags in this file.",
+		usage:        "Path to a JSON file containing a list of flag keys (array of strings) or a GrowthBook SDK features payload. The scanner will search for references to the flags in this file. Required unless apiKey is provided.",
 	},
 	{
 		name:         "outFile",
@@ -76,4 +162,29 @@ whether a feature flag was removed from code. May be set to 0 to disabled this f
 		defaultValue: "",
 		usage:        "Repository name. If not provided, will be omitted from output JSON file.",
 	},
+	{
+		name:         "since",
+		defaultValue: "",
+		usage: `If provided, commits authored before this time are not searched in history for extinctions, introductions or
+stale flags. May be a date (YYYY-MM-DD), an RFC 3339 timestamp or a duration before now such as "72h", "30d" or "12w".
+With "firstParent", reading stops after a run of older commits, otherwise the whole history is read.`,
+	},
+	{
+		name:         "staleLookback",
+		defaultValue: 1000,
+		usage: `Sets the number of git commits the stale command searches in history for the commit that last removed
+each flag without references. May be set to 0 to list flags without searching history.`,
+	},
+	{
+		name:         "until",
+		defaultValue: "",
+		usage: `If provided, commits authored after this time are not searched in history for extinctions, introductions or
+stale flags. Accepts the same values as "since", and a date means the end of that day.`,
+	},
+	{
+		name:         "upload",
+		defaultValue: false,
+		usage: `Uploads code references to the GrowthBook API at apiHost after they are written. Requires apiKey.
+Large scans are split into several gzipped requests.`,
+	},
 }
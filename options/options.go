@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/iancoleman/strcase"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -14,23 +17,60 @@ import (
 	"github.com/growthbook/gb-find-code-refs/internal/validation"
 )
 
+// OutputFormats are the supported values of the format option
+var OutputFormats = []string{"json", "sarif", "csv", "ndjson", "markdown"}
+
 type Options struct {
-	Branch       string `mapstructure:"branch"`
-	Dir          string `mapstructure:"dir" yaml:"-"`
-	OutDir       string `mapstructure:"outDir"`
-	Revision     string `mapstructure:"revision"`
-	FlagsPath    string `mapstructure:"flagsPath"`
-	OutFile      string `mapstructure:"outFile"`
-	RepoName     string `mapstructure:"repoName"`
-	ContextLines int    `mapstructure:"contextLines"`
-	Lookback     int    `mapstructure:"lookback"`
-	AllowTags    bool   `mapstructure:"allowTags"`
-	Debug        bool   `mapstructure:"debug"`
+	Branch       string   `mapstructure:"branch"`
+	Dir          string   `mapstructure:"dir" yaml:"-"`
+	OutDir       string   `mapstructure:"outDir"`
+	Revision     string   `mapstructure:"revision"`
+	Ref          string   `mapstructure:"ref"`
+	FlagsPath    string   `mapstructure:"flagsPath"`
+	Formats      []string `mapstructure:"format"`
+	ApiHost      string   `mapstructure:"apiHost"`
+	ApiKey       string   `mapstructure:"apiKey" yaml:"-"`
+	OutFile      string   `mapstructure:"outFile"`
+	RepoName     string   `mapstructure:"repoName"`
+	ContextLines int      `mapstructure:"contextLines"`
+	Lookback     int      `mapstructure:"lookback"`
+	AllowTags    bool     `mapstructure:"allowTags"`
+	BaseRef      string   `mapstructure:"baseRef"`
+	Debug        bool     `mapstructure:"debug"`
+	Upload       bool     `mapstructure:"upload"`
+	AliasReport  bool     `mapstructure:"aliasReport"`
+	Blame        bool     `mapstructure:"blame"`
+	DryRun       bool     `mapstructure:"dryRun"`
+	Since        string   `mapstructure:"since"`
+	Until        string   `mapstructure:"until"`
+	FirstParent  bool     `mapstructure:"firstParent"`
+	BranchGlob   []string `mapstructure:"branchGlob"`
+	BranchMaxAge string   `mapstructure:"branchMaxAge"`
+
+	FailOnAliasCollision bool `mapstructure:"failOnAliasCollision"`
+	HistoryInterval      int  `mapstructure:"historyInterval"`
+	Introductions        bool `mapstructure:"introductions"`
+	StaleLookback        int  `mapstructure:"staleLookback"`
 
 	// The following options can only be configured via YAML configuration
 
-	Aliases    []Alias    `mapstructure:"aliases"`
-	Delimiters Delimiters `mapstructure:"delimiters"`
+	Aliases     []Alias     `mapstructure:"aliases"`
+	Delimiters  Delimiters  `mapstructure:"delimiters"`
+	FlagFilters FlagFilters `mapstructure:"flagFilters"`
+	Projects    []Project   `mapstructure:"projects"`
+	Policy      Policy      `mapstructure:"policy"`
+}
+
+// Project is a subdirectory of the repository scanned with its own flags, aliases and delimiters
+type Project struct {
+	Key string `mapstructure:"key"`
+	Dir string `mapstructure:"dir"`
+
+	// Path to a flags file for this project, relative to the repository directory. If not set, the flags
+	// of the top level flags source whose project matches Key are used.
+	FlagsPath  string      `mapstructure:"flagsPath"`
+	Aliases    []Alias     `mapstructure:"aliases"`
+	Delimiters *Delimiters `mapstructure:"delimiters"`
 }
 
 type Delimiters struct {
@@ -39,6 +79,45 @@ type Delimiters struct {
 	Additional      []string `mapstructure:"additional"`
 }
 
+// FlagFilters restricts the flags that are searched for based on flag metadata. Empty filters match all flags.
+type FlagFilters struct {
+	ExcludeArchived bool     `mapstructure:"excludeArchived"`
+	Tags            []string `mapstructure:"tags"`     // flags must have at least one of these tags
+	Projects        []string `mapstructure:"projects"` // flags must belong to one of these projects
+	Owners          []string `mapstructure:"owners"`   // flags must be owned by one of these owners
+}
+
+// Matches reports whether a flag with the given metadata passes the filters
+func (f FlagFilters) Matches(project, owner string, tags []string, archived bool) bool {
+	if f.ExcludeArchived && archived {
+		return false
+	}
+	if len(f.Projects) > 0 && !contains(f.Projects, project) {
+		return false
+	}
+	if len(f.Owners) > 0 && !contains(f.Owners, owner) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		for _, tag := range tags {
+			if contains(f.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func Init(flagSet *pflag.FlagSet) error {
 	for _, f := range flags {
 		usage := strings.ReplaceAll(f.usage, "\n", " ")
@@ -49,6 +128,8 @@ func Init(flagSet *pflag.FlagSet) error {
 			flagSet.IntP(f.name, f.short, value, usage)
 		case bool:
 			flagSet.BoolP(f.name, f.short, value, usage)
+		case []string:
+			flagSet.StringSliceP(f.name, f.short, value, usage)
 		}
 	}
 
@@ -129,8 +210,11 @@ func (o Options) ValidateRequired() error {
 	if o.Dir == "" {
 		missingRequiredOptions = append(missingRequiredOptions, "dir")
 	}
-	if o.FlagsPath == "" {
-		missingRequiredOptions = append(missingRequiredOptions, "flagsPath")
+	if o.FlagsPath == "" && o.ApiKey == "" && !o.allProjectsHaveFlagsPath() {
+		missingRequiredOptions = append(missingRequiredOptions, "flagsPath or apiKey")
+	}
+	if o.Upload && !o.DryRun && o.ApiKey == "" {
+		missingRequiredOptions = append(missingRequiredOptions, "apiKey")
 	}
 	if len(missingRequiredOptions) > 0 {
 		return fmt.Errorf("missing required option(s): %v", missingRequiredOptions)
@@ -150,14 +234,46 @@ func (o Options) Validate() error {
 		return fmt.Errorf(`invalid value %q for "contextLines": must be <= %d`, o.ContextLines, maxContextLines)
 	}
 
-	// match all non-control ASCII characters
-	validDelims := regexp.MustCompile("^[\x20-\x7E]$")
-	for i, d := range o.Delimiters.Additional {
-		if !validDelims.MatchString(d) {
-			return fmt.Errorf(`invalid value %q for "delimiters.additional[%d]": each delimiter must be a valid non-control ASCII character`, d, i)
+	for _, format := range o.Formats {
+		if !contains(OutputFormats, format) {
+			return fmt.Errorf(`invalid value %q for "format": must be one of %s`, format, strings.Join(OutputFormats, ", "))
 		}
 	}
 
+	if err := o.Delimiters.validate("delimiters"); err != nil {
+		return err
+	}
+
+	if err := o.Policy.validate(); err != nil {
+		return err
+	}
+
+	// 0, the zero value of library configurations, counts references at every commit like 1
+	if o.HistoryInterval < 0 {
+		return fmt.Errorf(`invalid value %d for "historyInterval": must be >= 0`, o.HistoryInterval)
+	}
+
+	if o.StaleLookback < 0 {
+		return fmt.Errorf(`invalid value %d for "staleLookback": must be >= 0`, o.StaleLookback)
+	}
+
+	since, until, err := o.HistoryWindow()
+	if err != nil {
+		return err
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return fmt.Errorf(`invalid value %q for "until": must not be before "since"`, o.Until)
+	}
+
+	for i, pattern := range o.BranchGlob {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf(`invalid value %q for "branchGlob[%d]": must be a valid glob pattern`, pattern, i)
+		}
+	}
+	if _, err := o.BranchMaxAgeTime(); err != nil {
+		return err
+	}
+
 	if _, err := validation.NormalizeAndValidatePath(o.Dir); err != nil {
 		return fmt.Errorf(`invalid value for "dir": %+v`, err)
 	}
@@ -178,9 +294,136 @@ func (o Options) Validate() error {
 		return fmt.Errorf(`"branch" option is required when "revision" option is set`)
 	}
 
+	if o.Blame && o.Revision != "" {
+		return fmt.Errorf(`"blame" option requires a git repository and may not be used with the "revision" option`)
+	}
+
+	if o.Ref != "" && o.Revision != "" {
+		return fmt.Errorf(`"ref" option requires a git repository and may not be used with the "revision" option`)
+	}
+
+	if o.BaseRef != "" && o.Revision != "" {
+		return fmt.Errorf(`"baseRef" option requires a git repository and may not be used with the "revision" option`)
+	}
+
+	return o.validateProjects()
+}
+
+// SinceTime returns the time set by the since option, or the zero time if it is not set
+func (o Options) SinceTime() (time.Time, error) {
+	return parseHistoryTime("since", o.Since, time.Now())
+}
+
+// UntilTime returns the time set by the until option, or the zero time if it is not set. A date means the end of
+// that day, so that commits made on the day are included.
+func (o Options) UntilTime() (time.Time, error) {
+	until, err := parseHistoryTime("until", o.Until, time.Now())
+	if err == nil && dateOnlyRegex.MatchString(o.Until) {
+		until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return until, err
+}
+
+// BranchMaxAgeTime returns the time of the oldest last commit of the branches scanned by the branches command, or
+// the zero time if the branchMaxAge option is not set
+func (o Options) BranchMaxAgeTime() (time.Time, error) {
+	return parseHistoryTime("branchMaxAge", o.BranchMaxAge, time.Now())
+}
+
+var (
+	relativeDurationRegex = regexp.MustCompile(`^(\d+)([dw])$`)
+	dateOnlyRegex         = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// parseHistoryTime parses a date, an RFC 3339 timestamp, or a duration before now such as "72h", "30d" or "12w"
+func parseHistoryTime(name, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	if match := relativeDurationRegex.FindStringSubmatch(value); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		days := n
+		if match[2] == "w" {
+			days = n * 7
+		}
+		return now.AddDate(0, 0, -days), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf(`invalid value %q for "%s": must be a date (YYYY-MM-DD), an RFC 3339 timestamp or a duration such as "72h", "30d" or "12w"`, value, name)
+}
+
+// HistoryWindow returns the since and until times bounding searches of git history
+func (o Options) HistoryWindow() (since, until time.Time, err error) {
+	if since, err = o.SinceTime(); err != nil {
+		return since, until, err
+	}
+	until, err = o.UntilTime()
+	return since, until, err
+}
+
+func (o Options) validateProjects() error {
+	projectKeys := make(map[string]bool, len(o.Projects))
+	for i, p := range o.Projects {
+		if p.Key == "" {
+			return fmt.Errorf(`missing value for "projects[%d].key"`, i)
+		}
+		if projectKeys[p.Key] {
+			return fmt.Errorf(`duplicate project key %q in "projects"`, p.Key)
+		}
+		projectKeys[p.Key] = true
+
+		if p.Dir != "" {
+			if err := validation.IsSubDirValid(o.Dir, p.Dir); err != nil {
+				return fmt.Errorf(`invalid value %q for "projects[%d].dir": %+v`, p.Dir, i, err)
+			}
+		}
+		for _, a := range p.Aliases {
+			if err := a.IsValid(); err != nil {
+				return fmt.Errorf(`invalid alias for project %q: %w`, p.Key, err)
+			}
+		}
+		if p.Delimiters != nil {
+			if err := p.Delimiters.validate(fmt.Sprintf("projects[%d].delimiters", i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d Delimiters) validate(field string) error {
+	// match all non-control ASCII characters
+	validDelims := regexp.MustCompile("^[\x20-\x7E]$")
+	for i, delim := range d.Additional {
+		if !validDelims.MatchString(delim) {
+			return fmt.Errorf(`invalid value %q for "%s.additional[%d]": each delimiter must be a valid non-control ASCII character`, delim, field, i)
+		}
+	}
 	return nil
 }
 
+func (o Options) allProjectsHaveFlagsPath() bool {
+	if len(o.Projects) == 0 {
+		return false
+	}
+	for _, p := range o.Projects {
+		if p.FlagsPath == "" {
+			return false
+		}
+	}
+	return true
+}
+
 func (o Options) GetProjectKeys() (projects []string) {
+	for _, p := range o.Projects {
+		projects = append(projects, p.Key)
+	}
 	return projects
 }
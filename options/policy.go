@@ -0,0 +1,72 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Policy configures the rules enforced by the check command. Rules left unset are not enforced.
+type Policy struct {
+	ForbiddenFlags       ForbiddenFlags `mapstructure:"forbiddenFlags"`
+	MaxReferencesPerFlag int            `mapstructure:"maxReferencesPerFlag"`
+	MaxFilesPerFlag      int            `mapstructure:"maxFilesPerFlag"`
+	MaxFlagsPerFile      int            `mapstructure:"maxFlagsPerFile"`
+
+	// Glob patterns, relative to the repository directory, of files where flags may not be referenced
+	DisallowedPaths []string `mapstructure:"disallowedPaths"`
+}
+
+// ForbiddenFlags selects flags that may not be referenced at all. A flag is forbidden if it matches any of the fields.
+type ForbiddenFlags struct {
+	Keys     []string `mapstructure:"keys"`
+	Archived bool     `mapstructure:"archived"`
+	Tags     []string `mapstructure:"tags"`
+	Projects []string `mapstructure:"projects"`
+	Owners   []string `mapstructure:"owners"`
+}
+
+// Matches reports whether a flag with the given key and metadata is forbidden
+func (f ForbiddenFlags) Matches(key, project, owner string, tags []string, archived bool) bool {
+	if contains(f.Keys, key) || (f.Archived && archived) || contains(f.Projects, project) || contains(f.Owners, owner) {
+		return true
+	}
+	for _, tag := range tags {
+		if contains(f.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f ForbiddenFlags) isEmpty() bool {
+	return len(f.Keys) == 0 && !f.Archived && len(f.Tags) == 0 && len(f.Projects) == 0 && len(f.Owners) == 0
+}
+
+// IsEmpty reports whether no rules are configured
+func (p Policy) IsEmpty() bool {
+	return p.ForbiddenFlags.isEmpty() && p.MaxReferencesPerFlag == 0 && p.MaxFilesPerFlag == 0 &&
+		p.MaxFlagsPerFile == 0 && len(p.DisallowedPaths) == 0
+}
+
+func (p Policy) validate() error {
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"maxReferencesPerFlag", p.MaxReferencesPerFlag},
+		{"maxFilesPerFlag", p.MaxFilesPerFlag},
+		{"maxFlagsPerFile", p.MaxFlagsPerFile},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			return fmt.Errorf(`invalid value %d for "policy.%s": must be >= 0`, limit.value, limit.name)
+		}
+	}
+	for i, pattern := range p.DisallowedPaths {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf(`invalid value %q for "policy.disallowedPaths[%d]": must be a valid glob pattern`, pattern, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/internal/helpers"
+)
+
+// commonWords are words frequently found in source code. Aliases contained in these words are likely to match
+// unrelated code.
+var commonWords = []string{
+	"active", "admin", "button", "cache", "checkout", "client", "config", "content", "context", "create", "default",
+	"delete", "disabled", "enabled", "error", "event", "export", "false", "feature", "filter", "function", "handler",
+	"header", "import", "index", "label", "layout", "loading", "message", "model", "module", "number", "object",
+	"option", "order", "page", "params", "payment", "price", "product", "profile", "props", "request", "response",
+	"result", "return", "search", "service", "session", "settings", "state", "status", "string", "style", "submit",
+	"test", "title", "token", "update", "user", "value", "version", "window",
+}
+
+// AliasReport lists aliases that are likely to be misconfigured
+type AliasReport struct {
+	// Unused are aliases without any references. It is empty if Truncated is set.
+	Unused []AliasRef `json:"unused"`
+	// Truncated is set if the search stopped at the maximum number of references, so unused aliases are not known
+	Truncated bool `json:"truncated,omitempty"`
+	// Collisions are aliases generated for several flags of the same project, or equal to the key of another flag
+	Collisions []AliasCollision `json:"collisions"`
+	// CommonWords are aliases contained in common words, which are likely to produce false positives
+	CommonWords []AliasCommonWords `json:"commonWords"`
+}
+
+type AliasRef struct {
+	ProjKey string `json:"projKey,omitempty"`
+	FlagKey string `json:"flagKey"`
+	Alias   string `json:"alias"`
+}
+
+type AliasCollision struct {
+	ProjKey  string   `json:"projKey,omitempty"`
+	Alias    string   `json:"alias"`
+	FlagKeys []string `json:"flagKeys"`
+}
+
+type AliasCommonWords struct {
+	AliasRef
+	Words []string `json:"words"`
+}
+
+// IsEmpty reports whether no issues were found
+func (r AliasReport) IsEmpty() bool {
+	return len(r.Unused) == 0 && len(r.Collisions) == 0 && len(r.CommonWords) == 0
+}
+
+// BuildAliasReport checks the aliases of each project of matcher against the references found with it. Unused aliases
+// are only listed if the search found every reference.
+func BuildAliasReport(matcher Matcher, refs []gb.ReferenceHunksRep) AliasReport {
+	// aliases with references, keyed by project and flag
+	hits := map[string]map[string]map[string]bool{}
+	for _, ref := range refs {
+		for _, hunk := range ref.Hunks {
+			if hits[hunk.ProjKey] == nil {
+				hits[hunk.ProjKey] = map[string]map[string]bool{}
+			}
+			if hits[hunk.ProjKey][hunk.FlagKey] == nil {
+				hits[hunk.ProjKey][hunk.FlagKey] = map[string]bool{}
+			}
+			for _, alias := range hunk.Aliases {
+				hits[hunk.ProjKey][hunk.FlagKey][alias] = true
+			}
+		}
+	}
+
+	report := AliasReport{
+		Unused:      []AliasRef{},
+		Truncated:   isTruncated(refs),
+		Collisions:  []AliasCollision{},
+		CommonWords: []AliasCommonWords{},
+	}
+	for _, em := range matcher.Elements {
+		isFlagKey := make(map[string]bool, len(em.Elements))
+		for _, flagKey := range em.Elements {
+			isFlagKey[flagKey] = true
+		}
+		flagKeysByAlias := map[string][]string{}
+		for _, flagKey := range em.Elements {
+			for _, alias := range em.aliasesByElement[flagKey] {
+				flagKeysByAlias[alias] = append(flagKeysByAlias[alias], flagKey)
+				ref := AliasRef{ProjKey: em.ProjKey, FlagKey: flagKey, Alias: alias}
+				if !report.Truncated && !hits[em.ProjKey][flagKey][alias] {
+					report.Unused = append(report.Unused, ref)
+				}
+				if words := containingWords(alias); len(words) > 0 {
+					report.CommonWords = append(report.CommonWords, AliasCommonWords{AliasRef: ref, Words: words})
+				}
+			}
+		}
+		for alias, flagKeys := range flagKeysByAlias {
+			// an alias equal to the key of another flag also matches the references of that flag
+			if isFlagKey[alias] {
+				flagKeys = helpers.Dedupe(append(flagKeys, alias))
+			}
+			if len(flagKeys) > 1 {
+				sort.Strings(flagKeys)
+				report.Collisions = append(report.Collisions, AliasCollision{ProjKey: em.ProjKey, Alias: alias, FlagKeys: flagKeys})
+			}
+		}
+	}
+
+	sort.Slice(report.Collisions, func(i, j int) bool {
+		if report.Collisions[i].ProjKey != report.Collisions[j].ProjKey {
+			return report.Collisions[i].ProjKey < report.Collisions[j].ProjKey
+		}
+		return report.Collisions[i].Alias < report.Collisions[j].Alias
+	})
+	return report
+}
+
+// isTruncated reports whether refs were cut short at the maximum number of files or hunks searched for
+func isTruncated(refs []gb.ReferenceHunksRep) bool {
+	if len(refs) >= maxFileCount {
+		return true
+	}
+	totalHunks := 0
+	for _, ref := range refs {
+		totalHunks += len(ref.Hunks)
+	}
+	return totalHunks > maxHunkCount
+}
+
+// containingWords returns the common words that contain alias, ignoring case
+func containingWords(alias string) []string {
+	alias = strings.ToLower(alias)
+	words := []string{}
+	for _, word := range commonWords {
+		if strings.Contains(word, alias) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
@@ -1,18 +1,30 @@
 package search
 
 import (
+	"regexp"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
 	"github.com/growthbook/gb-find-code-refs/internal/helpers"
 	ahocorasick "github.com/petar-dambovaliev/aho-corasick"
 )
 
 type ElementMatcher struct {
+	ProjKey                     string
 	Elements                    []string
 	Dir                         string
 	allElementAndAliasesMatcher ahocorasick.AhoCorasick
 	matcherByElement            map[string]ahocorasick.AhoCorasick
 	aliasMatcherByElement       map[string]ahocorasick.AhoCorasick
+	aliasesByElement            map[string][]string
+	flagsByElement              map[string]gb.FlagRep
 
 	elementsByPatternIndex [][]string
+
+	// Regex aliases with a literal prefix are only evaluated on lines containing the prefix, others on every line
+	regexesByElement  map[string][]*regexp.Regexp
+	regexPrefilter    ahocorasick.AhoCorasick
+	regexesByPrefix   [][]elementRegex
+	unfilteredRegexes []elementRegex
 }
 
 func (m ElementMatcher) FindMatches(line string) []string {
@@ -21,6 +33,11 @@ func (m ElementMatcher) FindMatches(line string) []string {
 	for match := iter.Next(); match != nil; match = iter.Next() {
 		elements = append(elements, m.elementsByPatternIndex[match.Pattern()]...)
 	}
+	for _, candidate := range m.findRegexCandidates(line) {
+		if candidate.re.MatchString(line) {
+			elements = append(elements, candidate.element)
+		}
+	}
 	return helpers.Dedupe(elements)
 }
 
@@ -32,10 +49,84 @@ func (m ElementMatcher) FindAliases(line, element string) []string {
 			aliasMatches = append(aliasMatches, line[match.Start():match.End()])
 		}
 	}
-	return aliasMatches
+	if regexes, exists := m.regexesByElement[element]; exists {
+		for _, re := range regexes {
+			aliasMatches = append(aliasMatches, re.FindAllString(line, -1)...)
+		}
+	}
+	return helpers.Dedupe(aliasMatches)
+}
+
+// elementRegex is a regex alias of an element
+type elementRegex struct {
+	element string
+	re      *regexp.Regexp
+}
+
+// findRegexCandidates returns the regex aliases that may match line: those whose literal prefix occurs in line, and
+// those without a literal prefix
+func (m ElementMatcher) findRegexCandidates(line string) []elementRegex {
+	candidates := append([]elementRegex{}, m.unfilteredRegexes...)
+	if len(m.regexesByPrefix) == 0 {
+		return candidates
+	}
+	seen := map[int]bool{}
+	iter := m.regexPrefilter.IterOverlapping(line)
+	for match := iter.Next(); match != nil; match = iter.Next() {
+		if !seen[match.Pattern()] {
+			seen[match.Pattern()] = true
+			candidates = append(candidates, m.regexesByPrefix[match.Pattern()]...)
+		}
+	}
+	return candidates
+}
+
+// setRegexAliases adds regex aliases to the matcher, with a prefilter on their literal prefixes. The prefix is
+// taken from the expanded pattern, since it may contain a transformed flag key rather than the raw one. Patterns
+// without a literal prefix, such as case insensitive ones, are evaluated on every line.
+func (m *ElementMatcher) setRegexAliases(regexesByElement map[string][]*regexp.Regexp) {
+	m.regexesByElement = regexesByElement
+	prefixes := make([]string, 0)
+	prefixIndex := map[string]int{}
+	for _, element := range m.Elements {
+		for _, re := range regexesByElement[element] {
+			candidate := elementRegex{element: element, re: re}
+			prefix, _ := re.LiteralPrefix()
+			if prefix == "" {
+				m.unfilteredRegexes = append(m.unfilteredRegexes, candidate)
+				continue
+			}
+			index, exists := prefixIndex[prefix]
+			if !exists {
+				index = len(prefixes)
+				prefixIndex[prefix] = index
+				prefixes = append(prefixes, prefix)
+				m.regexesByPrefix = append(m.regexesByPrefix, nil)
+			}
+			m.regexesByPrefix[index] = append(m.regexesByPrefix[index], candidate)
+		}
+	}
+	if len(prefixes) > 0 {
+		builder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{DFA: true, MatchKind: ahocorasick.StandardMatch})
+		m.regexPrefilter = builder.Build(prefixes)
+	}
+}
+
+// annotate records the project of the matcher and the metadata of the flag matching element on hunk
+func (m ElementMatcher) annotate(hunk gb.HunkRep, element string) gb.HunkRep {
+	hunk.ProjKey = m.ProjKey
+	if flag, exists := m.flagsByElement[element]; exists {
+		return hunk.WithFlag(flag)
+	}
+	return hunk
+}
+
+// MatchesPath reports whether a file path is within the directory of the matcher
+func (m ElementMatcher) MatchesPath(path string) bool {
+	return helpers.IsInDir(path, m.Dir)
 }
 
-func NewElementMatcher(dir, delimiters string, elements []string, aliasesByElement map[string][]string) ElementMatcher {
+func NewElementMatcher(projKey, dir, delimiters string, elements []string, aliasesByElement map[string][]string) ElementMatcher {
 	matcherBuilder := ahocorasick.NewAhoCorasickBuilder(ahocorasick.Opts{DFA: true, MatchKind: ahocorasick.StandardMatch})
 
 	allFlagPatternsAndAliases := make([]string, 0)
@@ -69,10 +160,12 @@ func NewElementMatcher(dir, delimiters string, elements []string, aliasesByEleme
 	}
 
 	return ElementMatcher{
+		ProjKey:                     projKey,
 		Elements:                    elements,
 		Dir:                         dir,
 		matcherByElement:            flagMatcherByKey,
 		aliasMatcherByElement:       aliasMatcherByElement,
+		aliasesByElement:            aliasesByElement,
 		allElementAndAliasesMatcher: matcherBuilder.Build(allFlagPatternsAndAliases),
 
 		elementsByPatternIndex: elementsByPatternIndex,
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +15,8 @@ import (
 	"github.com/growthbook/gb-find-code-refs/internal/validation"
 )
 
+var ignoreFiles = []string{".gitignore", ".ignore", ".gbignore"}
+
 type ignore struct {
 	path    string
 	ignores []gitignore.IgnoreMatcher
@@ -41,6 +44,20 @@ func (m ignore) Match(path string, isDir bool) bool {
 	return false
 }
 
+// IgnoredPaths returns a function that reports whether a slash-separated path relative to workspace is excluded
+// from scanning, either because it is hidden or because it matches the ignore files in workspace.
+func IgnoredPaths(workspace string) func(path string) bool {
+	allIgnores := newIgnore(workspace, ignoreFiles)
+	return func(path string) bool {
+		for _, segment := range strings.Split(path, "/") {
+			if strings.HasPrefix(segment, ".") {
+				return true
+			}
+		}
+		return allIgnores.Match(filepath.Join(workspace, filepath.FromSlash(path)), false)
+	}
+}
+
 func readFileLines(path string) ([]string, error) {
 	if !validation.FileExists(path) {
 		return nil, errors.New("file does not exist")
@@ -53,7 +70,11 @@ func readFileLines(path string) ([]string, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return readLines(file), nil
+}
+
+func readLines(r io.Reader) []string {
+	scanner := bufio.NewScanner(r)
 	scanner.Split(bufio.ScanLines)
 	var lines []string
 
@@ -61,12 +82,11 @@ func readFileLines(path string) ([]string, error) {
 		lines = append(lines, scanner.Text())
 	}
 
-	return lines, nil
+	return lines
 }
 
 func readFiles(ctx context.Context, files chan<- file, workspace string) error {
 	defer close(files)
-	ignoreFiles := []string{".gitignore", ".ignore", ".gbignore"}
 	allIgnores := newIgnore(workspace, ignoreFiles)
 	workspace = filepath.ToSlash(workspace)
 
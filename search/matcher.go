@@ -1,11 +1,15 @@
 package search
 
 import (
+	"context"
+	"fmt"
+	"io/fs"
 	"strings"
 
 	"github.com/growthbook/gb-find-code-refs/aliases"
+	"github.com/growthbook/gb-find-code-refs/flags"
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
 	"github.com/growthbook/gb-find-code-refs/internal/helpers"
-	"github.com/growthbook/gb-find-code-refs/internal/log"
 	"github.com/growthbook/gb-find-code-refs/options"
 )
 
@@ -14,23 +18,50 @@ type Matcher struct {
 	ctxLines int
 }
 
-func NewMultiProjectMatcher(opts options.Options, dir string, flagKeys []string) Matcher {
-	elements := make([]ElementMatcher, 0, 1)
-	delimiters := strings.Join(GetDelimiters(opts), "")
-
-	projectFlags := flagKeys
-	projectAliases := opts.Aliases
-	aliasesByFlagKey, err := aliases.GenerateAliases(projectFlags, projectAliases, dir)
-	if err != nil {
-		log.Error.Fatalf("failed to generate aliases: %s", err)
+// NewMultiProjectMatcher builds an element matcher for each configured project. When no projects are
+// configured, a single matcher for the whole directory is built from the flags keyed by an empty project key. Alias
+// files are read from files, or from dir if files is nil.
+func NewMultiProjectMatcher(ctx context.Context, opts options.Options, dir string, files fs.FS, flagsByProject map[string][]gb.FlagRep) (Matcher, error) {
+	projects := opts.Projects
+	if len(projects) == 0 {
+		projects = []options.Project{{}}
 	}
 
-	elements = append(elements, NewElementMatcher("", delimiters, projectFlags, aliasesByFlagKey))
+	elements := make([]ElementMatcher, 0, len(projects))
+	for _, project := range projects {
+		projectOpts := opts
+		if len(project.Aliases) > 0 {
+			projectOpts.Aliases = project.Aliases
+		}
+		if project.Delimiters != nil {
+			projectOpts.Delimiters = *project.Delimiters
+		}
+		delimiters := strings.Join(GetDelimiters(projectOpts), "")
+
+		projectFlags := flags.Keys(flagsByProject[project.Key])
+		aliasesByFlagKey, err := aliases.GenerateAliases(ctx, projectFlags, projectOpts.Aliases, dir, files)
+		if err != nil {
+			return Matcher{}, fmt.Errorf("failed to generate aliases for project %q: %w", project.Key, err)
+		}
+
+		regexesByFlagKey, err := aliases.GenerateRegexAliases(projectFlags, projectOpts.Aliases)
+		if err != nil {
+			return Matcher{}, fmt.Errorf("failed to generate aliases for project %q: %w", project.Key, err)
+		}
+
+		elementMatcher := NewElementMatcher(project.Key, project.Dir, delimiters, projectFlags, aliasesByFlagKey)
+		elementMatcher.setRegexAliases(regexesByFlagKey)
+		elementMatcher.flagsByElement = make(map[string]gb.FlagRep, len(projectFlags))
+		for _, flag := range flagsByProject[project.Key] {
+			elementMatcher.flagsByElement[flag.Key] = flag
+		}
+		elements = append(elements, elementMatcher)
+	}
 
 	return Matcher{
 		ctxLines: opts.ContextLines,
 		Elements: elements,
-	}
+	}, nil
 }
 
 func (m Matcher) MatchElement(line, element string) bool {
@@ -50,6 +81,17 @@ func (m Matcher) GetElementMatcher() *ElementMatcher {
 	return &elementMatcher
 }
 
+// GetProjectElementMatcher returns the element matcher for a project, or nil if the project was not configured
+func (m Matcher) GetProjectElementMatcher(projKey string) *ElementMatcher {
+	for _, em := range m.Elements {
+		if em.ProjKey == projKey {
+			elementMatcher := em
+			return &elementMatcher
+		}
+	}
+	return nil
+}
+
 func (m Matcher) FindAliases(line, element string) []string {
 	matches := make([]string, 0)
 	for _, em := range m.Elements {
@@ -65,6 +107,14 @@ func (m Matcher) GetElements() (elements [][]string) {
 	return elements
 }
 
+func (m Matcher) GetElementsByProject() map[string][]string {
+	elements := make(map[string][]string, len(m.Elements))
+	for _, element := range m.Elements {
+		elements[element.ProjKey] = append(elements[element.ProjKey], element.Elements...)
+	}
+	return elements
+}
+
 func buildElementPatterns(flags []string, delimiters string) map[string][]string {
 	patternsByFlag := make(map[string][]string, len(flags))
 	for _, flag := range flags {
@@ -1,10 +1,15 @@
 package search
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/growthbook/gb-find-code-refs/aliases"
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+	"github.com/growthbook/gb-find-code-refs/options"
 )
 
 func Test_buildFlagPatterns(t *testing.T) {
@@ -16,16 +21,44 @@ func Test_buildFlagPatterns(t *testing.T) {
 
 func TestElementMatcher_FindAliases(t *testing.T) {
 	t.Run("overlapping aliases are reported separately", func(t *testing.T) {
-		matcher := NewElementMatcher("", "", nil, map[string][]string{"flag": {"alias", "alias1"}})
+		matcher := NewElementMatcher("", "", "", nil, map[string][]string{"flag": {"alias", "alias1"}})
 		assert.ElementsMatch(t, []string{"alias", "alias1"}, matcher.FindAliases("alias1", "flag"))
 	})
+
+	t.Run("regex alias matches are reported", func(t *testing.T) {
+		matcher := NewElementMatcher("", "", "'", []string{"checkout"}, nil)
+		matcher.setRegexAliases(map[string][]*regexp.Regexp{"checkout": {regexp.MustCompile(`FF_checkout_V\d+`)}})
+		assert.Equal(t, []string{"FF_checkout_V2", "FF_checkout_V3"}, matcher.FindAliases("if (FF_checkout_V2 || FF_checkout_V3)", "checkout"))
+	})
 }
 
 func TestElementMatcher_FindMatches(t *testing.T) {
 	t.Run("overlapping flags are reported separately", func(t *testing.T) {
-		matcher := NewElementMatcher("", "", []string{"flag", "flag1"}, nil)
+		matcher := NewElementMatcher("", "", "", []string{"flag", "flag1"}, nil)
 		assert.ElementsMatch(t, []string{"flag", "flag1"}, matcher.FindMatches("flag1"))
 	})
+
+	t.Run("regex aliases are matched", func(t *testing.T) {
+		matcher := NewElementMatcher("", "", "'", []string{"checkout", "search"}, nil)
+		matcher.setRegexAliases(map[string][]*regexp.Regexp{"checkout": {regexp.MustCompile(`FF_checkout_V\d+`)}})
+		assert.Equal(t, []string{"checkout"}, matcher.FindMatches("if (FF_checkout_V2) {"))
+		assert.Empty(t, matcher.FindMatches("if (FF_checkout_VX) {"))
+		assert.Empty(t, matcher.FindMatches("if (FF_search_V2) {"))
+	})
+
+	t.Run("regex aliases are matched on lines without the flag key", func(t *testing.T) {
+		elements := []string{"new-checkout", "search"}
+		regexes, err := aliases.GenerateRegexAliases(elements, []options.Alias{
+			{Type: options.Regex, Patterns: []string{`FF_{FLAG_KEY:upper_snake}_V\d+`, `(?i){FLAG_KEY}_enabled`}},
+		})
+		require.NoError(t, err)
+		matcher := NewElementMatcher("", "", "'", elements, nil)
+		matcher.setRegexAliases(regexes)
+		assert.Equal(t, []string{"new-checkout"}, matcher.FindMatches("if (FF_NEW_CHECKOUT_V2) {"))
+		assert.Equal(t, []string{"FF_NEW_CHECKOUT_V2"}, matcher.FindAliases("if (FF_NEW_CHECKOUT_V2) {", "new-checkout"))
+		assert.Equal(t, []string{"search"}, matcher.FindMatches("if (SEARCH_ENABLED) {"))
+		assert.Empty(t, matcher.FindMatches("if (FF_NEW_CHECKOUT_VX) {"))
+	})
 }
 
 func TestMatcher_MatchElement(t *testing.T) {
@@ -40,21 +73,21 @@ func TestMatcher_MatchElement(t *testing.T) {
 			name:     "match found",
 			expected: true,
 			line:     "var flagKey = 'testflag'",
-			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", ",'\"", []string{"testflag"}, map[string][]string{"testflag": {"testFlag"}})}},
+			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", "", ",'\"", []string{"testflag"}, map[string][]string{"testflag": {"testFlag"}})}},
 			flagKey:  "testflag",
 		},
 		{
 			name:     "no match found",
 			expected: false,
 			line:     "var flagKey = 'testflag'",
-			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", ",'\"", []string{"anotherflag"}, map[string][]string{"anotherflag": {"anotherFlag"}})}},
+			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", "", ",'\"", []string{"anotherflag"}, map[string][]string{"anotherflag": {"anotherFlag"}})}},
 			flagKey:  "testflag",
 		},
 		{
 			name:     "doesn't match when delimiters aren't present",
 			expected: false,
 			line:     "var TEST_FLAG",
-			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", "'", []string{"TEST_FLAG"}, map[string][]string{"testflag": {}})}},
+			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", "", "'", []string{"TEST_FLAG"}, map[string][]string{"testflag": {}})}},
 			flagKey:  "TEST_FLAG",
 		},
 		{
@@ -62,7 +95,7 @@ func TestMatcher_MatchElement(t *testing.T) {
 			name:     "matches without delimiters",
 			expected: true,
 			line:     "var TEST_FLAG",
-			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", "", []string{"TEST_FLAG"}, map[string][]string{"testflag": {}})}},
+			matcher:  Matcher{Elements: []ElementMatcher{NewElementMatcher("", "", "", []string{"TEST_FLAG"}, map[string][]string{"testflag": {}})}},
 			flagKey:  "TEST_FLAG",
 		},
 	}
@@ -74,3 +107,58 @@ func TestMatcher_MatchElement(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildAliasReport(t *testing.T) {
+	matcher := Matcher{Elements: []ElementMatcher{
+		NewElementMatcher("", "", "'", []string{"flag-a", "flag-b"}, map[string][]string{
+			"flag-a": {"FlagA", "shared"},
+			"flag-b": {"FlagB", "shared", "user"},
+		}),
+		NewElementMatcher("other", "other", "'", []string{"flag-c"}, map[string][]string{
+			"flag-c": {"shared"},
+		}),
+	}}
+	refs := []gb.ReferenceHunksRep{{Path: "a.go", Hunks: []gb.HunkRep{
+		{FlagKey: "flag-a", Aliases: []string{"FlagA", "shared"}},
+		{FlagKey: "flag-b", Aliases: []string{"FlagB"}},
+		{FlagKey: "flag-c", ProjKey: "other", Aliases: []string{"shared"}},
+	}}}
+
+	report := BuildAliasReport(matcher, refs)
+	require.Equal(t, []AliasRef{
+		{FlagKey: "flag-b", Alias: "shared"},
+		{FlagKey: "flag-b", Alias: "user"},
+	}, report.Unused)
+	require.Equal(t, []AliasCollision{{Alias: "shared", FlagKeys: []string{"flag-a", "flag-b"}}}, report.Collisions)
+	require.Equal(t, []AliasCommonWords{{AliasRef: AliasRef{FlagKey: "flag-b", Alias: "user"}, Words: []string{"user"}}}, report.CommonWords)
+	require.False(t, report.Truncated)
+	require.False(t, report.IsEmpty())
+}
+
+func TestBuildAliasReport_FlagKeyCollision(t *testing.T) {
+	matcher := Matcher{Elements: []ElementMatcher{
+		NewElementMatcher("", "", "'", []string{"flag-a", "flagb"}, map[string][]string{
+			"flag-a": {"flagb"},
+		}),
+	}}
+
+	report := BuildAliasReport(matcher, nil)
+	require.Equal(t, []AliasCollision{{Alias: "flagb", FlagKeys: []string{"flag-a", "flagb"}}}, report.Collisions)
+}
+
+func TestBuildAliasReport_Truncated(t *testing.T) {
+	matcher := Matcher{Elements: []ElementMatcher{
+		NewElementMatcher("", "", "'", []string{"flag-a"}, map[string][]string{
+			"flag-a": {"FlagA"},
+		}),
+	}}
+	refs := make([]gb.ReferenceHunksRep, maxFileCount)
+
+	report := BuildAliasReport(matcher, refs)
+	require.True(t, report.Truncated)
+	require.Empty(t, report.Unused)
+
+	report = BuildAliasReport(matcher, refs[:1])
+	require.False(t, report.Truncated)
+	require.Equal(t, []AliasRef{{FlagKey: "flag-a", Alias: "FlagA"}}, report.Unused)
+}
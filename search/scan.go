@@ -1,21 +1,51 @@
 package search
 
 import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/growthbook/gb-find-code-refs/aliases"
 	"github.com/growthbook/gb-find-code-refs/flags"
 	"github.com/growthbook/gb-find-code-refs/internal/gb"
-	"github.com/growthbook/gb-find-code-refs/internal/log"
 	"github.com/growthbook/gb-find-code-refs/options"
 )
 
 // Scan checks the configured directory for flags based on the options configured for Code References.
-func Scan(opts options.Options, dir string) (Matcher, []gb.ReferenceHunksRep) {
-	flagKeys := flags.GetFlagKeys(opts)
-	matcher := NewMultiProjectMatcher(opts, dir, flagKeys)
+func Scan(ctx context.Context, opts options.Options, dir string) (Matcher, []gb.ReferenceHunksRep, error) {
+	matcher, err := BuildMatcher(ctx, opts, dir, nil)
+	if err != nil {
+		return Matcher{}, nil, err
+	}
 
-	refs, err := SearchForRefs(dir, matcher)
+	refs, err := SearchForRefs(ctx, dir, matcher)
 	if err != nil {
-		log.Error.Fatalf("error searching for flag key references: %s", err)
+		return Matcher{}, nil, fmt.Errorf("error searching for flag key references: %w", err)
 	}
 
-	return matcher, refs
+	return matcher, refs, nil
+}
+
+// BuildMatcher loads the configured flags and generates their aliases without scanning the directory. Alias files
+// are read from files, or from dir if files is nil.
+func BuildMatcher(ctx context.Context, opts options.Options, dir string, files fs.FS) (Matcher, error) {
+	flagsByProject, err := flags.GetFlagsByProject(ctx, opts)
+	if err != nil {
+		return Matcher{}, err
+	}
+	return NewMultiProjectMatcher(ctx, opts, dir, files, flagsByProject)
+}
+
+// ReadsAliasFiles reports whether any alias of opts or of its projects reads files, so that its aliases depend on
+// the commit searched
+func ReadsAliasFiles(opts options.Options) bool {
+	if aliases.ReadsFiles(opts.Aliases) {
+		return true
+	}
+	for _, project := range opts.Projects {
+		if aliases.ReadsFiles(project.Aliases) {
+			return true
+		}
+	}
+	return false
 }
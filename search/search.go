@@ -35,6 +35,11 @@ func truncateLine(line string, maxCharCount int) string {
 	return string(runes[0:maxCharCount]) + "…"
 }
 
+// TruncateLine truncates a line to the maximum number of characters included in code references
+func TruncateLine(line string) string {
+	return truncateLine(line, maxLineCharCount)
+}
+
 type file struct {
 	path  string
 	lines []string
@@ -105,18 +110,19 @@ func (f file) toHunks(matcher Matcher) *gb.ReferenceHunksRep {
 	hunks := make([]gb.HunkRep, 0)
 	filteredMatchers := make([]ElementMatcher, 0)
 	for _, elementSearch := range matcher.Elements {
-		if elementSearch.Dir != "" {
-			matchDir := strings.HasPrefix(f.path, elementSearch.Dir)
-			if !matchDir {
-				continue
-			}
+		if !elementSearch.MatchesPath(f.path) {
+			continue
 		}
 		filteredMatchers = append(filteredMatchers, elementSearch)
 	}
 	for _, elementSearch := range filteredMatchers {
+		// only consider the flags and aliases of the project the file belongs to
+		projectMatcher := Matcher{ctxLines: matcher.ctxLines, Elements: []ElementMatcher{elementSearch}}
 		lineNumbersByElement := f.findMatchingLineNumbersByElement(elementSearch)
 		for element, lineNumbers := range lineNumbersByElement {
-			hunks = append(hunks, f.aggregateHunksForFlag(element, matcher, lineNumbers)...)
+			for _, hunk := range f.aggregateHunksForFlag(element, projectMatcher, lineNumbers) {
+				hunks = append(hunks, elementSearch.annotate(hunk, element))
+			}
 		}
 	}
 	if len(hunks) == 0 {
@@ -191,15 +197,24 @@ func processFiles(ctx context.Context, files <-chan file, references chan<- gb.R
 	w.Wait()
 }
 
-func SearchForRefs(directory string, matcher Matcher) ([]gb.ReferenceHunksRep, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+// SearchForRefs returns the code references in all files of directory. If ctx is cancelled, the search
+// stops and the context error is returned.
+func SearchForRefs(ctx context.Context, directory string, matcher Matcher) ([]gb.ReferenceHunksRep, error) {
+	return searchForRefs(ctx, matcher, func(ctx context.Context, files chan<- file) error {
+		return readFiles(ctx, files, directory)
+	})
+}
+
+// searchForRefs returns the code references in the files sent by readFiles, which must close the channel when done
+func searchForRefs(ctx context.Context, matcher Matcher, readFiles func(context.Context, chan<- file) error) ([]gb.ReferenceHunksRep, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	files := make(chan file)
 	references := make(chan gb.ReferenceHunksRep)
 	// Start workers to process files asynchronously as they are written to the files channel
 	go processFiles(ctx, files, references, matcher)
 
-	err := readFiles(ctx, files, directory)
+	err := readFiles(ctx, files)
 	if err != nil {
 		return nil, err
 	}
@@ -224,6 +239,9 @@ func SearchForRefs(directory string, matcher Matcher) ([]gb.ReferenceHunksRep, e
 			return ret, nil
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return ret, nil
 }
 
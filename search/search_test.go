@@ -65,7 +65,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: 0,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, `"`, []string{testFlagKey}, nil),
+					NewElementMatcher("", ``, `"`, []string{testFlagKey}, nil),
 				},
 			},
 			lineNum: 0,
@@ -78,7 +78,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: 0,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, `"`, []string{testFlagKey}, nil),
+					NewElementMatcher("", ``, `"`, []string{testFlagKey}, nil),
 				},
 			},
 			lineNum: 0,
@@ -91,7 +91,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: -1,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, ``, []string{testFlagKey}, nil),
+					NewElementMatcher("", ``, ``, []string{testFlagKey}, nil),
 				},
 			},
 			lineNum: 0,
@@ -104,7 +104,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: -1,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, ``, nil, testAliases),
+					NewElementMatcher("", ``, ``, nil, testAliases),
 				},
 			},
 			lineNum: 0,
@@ -117,7 +117,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: -1,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, ``, nil, testAliases),
+					NewElementMatcher("", ``, ``, nil, testAliases),
 				},
 			},
 			lineNum: 0,
@@ -130,7 +130,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: 0,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, ``, []string{testFlagKey}, nil),
+					NewElementMatcher("", ``, ``, []string{testFlagKey}, nil),
 				},
 			},
 			lineNum: 1,
@@ -143,7 +143,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: 1,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, ``, []string{testFlagKey}, nil),
+					NewElementMatcher("", ``, ``, []string{testFlagKey}, nil),
 				},
 			},
 			lineNum: 1,
@@ -156,7 +156,7 @@ func Test_hunkForLine(t *testing.T) {
 			matcher: Matcher{
 				ctxLines: 0,
 				Elements: []ElementMatcher{
-					NewElementMatcher(``, ``, []string{testFlagKey}, nil),
+					NewElementMatcher("", ``, ``, []string{testFlagKey}, nil),
 				},
 			},
 			lineNum: 0,
@@ -188,7 +188,7 @@ func Test_aggregateHunksForFlag(t *testing.T) {
 			name: "does not set lines when context lines are disabled",
 			matcher: Matcher{
 				ctxLines: -1,
-				Elements: []ElementMatcher{NewElementMatcher(``, defaultDelims, []string{testFlagKey}, nil)},
+				Elements: []ElementMatcher{NewElementMatcher("", ``, defaultDelims, []string{testFlagKey}, nil)},
 			},
 			lines: []string{delimitedTestFlagKey, delimitedTestFlagKey, delimitedTestFlagKey},
 			want: []gb.HunkRep{
@@ -201,7 +201,7 @@ func Test_aggregateHunksForFlag(t *testing.T) {
 			name: "combines adjacent hunks with no additional context lines",
 			matcher: Matcher{
 				ctxLines: 0,
-				Elements: []ElementMatcher{NewElementMatcher(``, defaultDelims, []string{testFlagKey}, nil)},
+				Elements: []ElementMatcher{NewElementMatcher("", ``, defaultDelims, []string{testFlagKey}, nil)},
 			}, lines: []string{delimitedTestFlagKey, delimitedTestFlagKey, delimitedTestFlagKey},
 			want: []gb.HunkRep{
 				makeHunk(1, delimitedTestFlagKey, delimitedTestFlagKey, delimitedTestFlagKey),
@@ -211,7 +211,7 @@ func Test_aggregateHunksForFlag(t *testing.T) {
 			name: "combines adjacent hunks",
 			matcher: Matcher{
 				ctxLines: 1,
-				Elements: []ElementMatcher{NewElementMatcher(``, defaultDelims, []string{testFlagKey}, nil)},
+				Elements: []ElementMatcher{NewElementMatcher("", ``, defaultDelims, []string{testFlagKey}, nil)},
 			}, lines: []string{delimitedTestFlagKey, "", "", delimitedTestFlagKey, "", "", delimitedTestFlagKey},
 			want: []gb.HunkRep{
 				makeHunk(1, delimitedTestFlagKey, "", "", delimitedTestFlagKey, "", "", delimitedTestFlagKey),
@@ -221,7 +221,7 @@ func Test_aggregateHunksForFlag(t *testing.T) {
 			name: "does not combine hunks with no overlap",
 			matcher: Matcher{
 				ctxLines: 1,
-				Elements: []ElementMatcher{NewElementMatcher(``, defaultDelims, []string{testFlagKey}, nil)},
+				Elements: []ElementMatcher{NewElementMatcher("", ``, defaultDelims, []string{testFlagKey}, nil)},
 			},
 			lines: []string{delimitedTestFlagKey, "", "", "", delimitedTestFlagKey, "", "", "", delimitedTestFlagKey},
 			want: []gb.HunkRep{
@@ -234,7 +234,7 @@ func Test_aggregateHunksForFlag(t *testing.T) {
 			name: "combines overlapping hunks",
 			matcher: Matcher{
 				ctxLines: 1,
-				Elements: []ElementMatcher{NewElementMatcher(``, defaultDelims, []string{testFlagKey}, nil)},
+				Elements: []ElementMatcher{NewElementMatcher("", ``, defaultDelims, []string{testFlagKey}, nil)},
 			},
 			lines: []string{delimitedTestFlagKey, "", delimitedTestFlagKey, "", delimitedTestFlagKey},
 			want: []gb.HunkRep{
@@ -245,7 +245,7 @@ func Test_aggregateHunksForFlag(t *testing.T) {
 			name: "combines multiple types of overlaps",
 			matcher: Matcher{
 				ctxLines: 1,
-				Elements: []ElementMatcher{NewElementMatcher(``, defaultDelims, []string{testFlagKey}, nil)},
+				Elements: []ElementMatcher{NewElementMatcher("", ``, defaultDelims, []string{testFlagKey}, nil)},
 			},
 			lines: []string{delimitedTestFlagKey, "", delimitedTestFlagKey, "", delimitedTestFlagKey},
 			want: []gb.HunkRep{
@@ -339,7 +339,7 @@ func Test_toHunks(t *testing.T) {
 	matcher := Matcher{
 		ctxLines: 0,
 		Elements: []ElementMatcher{
-			NewElementMatcher("", "", []string{testFlagKey, testFlagKey2}, testAliases),
+			NewElementMatcher("", "", "", []string{testFlagKey, testFlagKey2}, testAliases),
 		},
 	}
 	got := f.toHunks(matcher)
@@ -349,12 +349,49 @@ func Test_toHunks(t *testing.T) {
 	emptyMatcher := Matcher{
 		ctxLines: 0,
 		Elements: []ElementMatcher{
-			NewElementMatcher("", "", nil, nil),
+			NewElementMatcher("", "", "", nil, nil),
 		},
 	}
 	require.Nil(t, f.toHunks(emptyMatcher))
 }
 
+func Test_toHunksWithFlagMetadata(t *testing.T) {
+	elementMatcher := NewElementMatcher("", "", "", []string{testFlagKey}, nil)
+	elementMatcher.flagsByElement = map[string]gb.FlagRep{
+		testFlagKey: {Key: testFlagKey, Owner: "jane", Tags: []string{"frontend"}, Archived: true},
+	}
+	matcher := Matcher{ctxLines: 0, Elements: []ElementMatcher{elementMatcher}}
+
+	got := testFile.toHunks(matcher)
+	require.NotNil(t, got)
+	for _, hunk := range got.Hunks {
+		require.Equal(t, "jane", hunk.Owner)
+		require.Equal(t, []string{"frontend"}, hunk.Tags)
+		require.True(t, hunk.Archived)
+	}
+}
+
+func Test_toHunksWithProjects(t *testing.T) {
+	matcher := Matcher{
+		ctxLines: 0,
+		Elements: []ElementMatcher{
+			NewElementMatcher("web", "apps/web", "", []string{testFlagKey}, nil),
+			NewElementMatcher("api", "apps/api", "", []string{testFlagKey2}, nil),
+		},
+	}
+
+	f := file{path: "apps/web/fileWithRefs", lines: testFile.lines}
+	got := f.toHunks(matcher)
+	require.NotNil(t, got)
+	for _, hunk := range got.Hunks {
+		require.Equal(t, testFlagKey, hunk.FlagKey)
+		require.Equal(t, "web", hunk.ProjKey)
+	}
+
+	f = file{path: "apps/website/fileWithRefs", lines: testFile.lines}
+	require.Nil(t, f.toHunks(matcher), "files outside of project directories should not be matched")
+}
+
 func Test_processFiles(t *testing.T) {
 	f := testFile
 	linesCopy := make([]string, len(f.lines))
@@ -371,7 +408,7 @@ func Test_processFiles(t *testing.T) {
 		ctxLines: 0,
 	}
 	matcher.Elements = append(matcher.Elements,
-		NewElementMatcher("", "", []string{testFlagKey, testFlagKey2}, testAliases),
+		NewElementMatcher("", "", "", []string{testFlagKey, testFlagKey2}, testAliases),
 	)
 	go processFiles(context.Background(), files, references, matcher)
 	totalRefs := 0
@@ -391,10 +428,10 @@ func Test_SearchForRefs(t *testing.T) {
 		ctxLines: 0,
 	}
 	matcher.Elements = append(matcher.Elements,
-		NewElementMatcher("", "", []string{testFlagKey, testFlagKey2}, nil),
+		NewElementMatcher("", "", "", []string{testFlagKey, testFlagKey2}, nil),
 	)
 	t.Cleanup(func() { os.Remove("testdata/symlink") })
-	got, err := SearchForRefs("testdata", matcher)
+	got, err := SearchForRefs(context.Background(), "testdata", matcher)
 	require.NoError(t, err)
 	require.Len(t, got, 1)
 	require.Equal(t, want[0].Path, got[0].Path)
@@ -0,0 +1,161 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/tools/godoc/util"
+
+	"github.com/growthbook/gb-find-code-refs/internal/gb"
+)
+
+// SearchTreeForRefs returns the code references in all files of a git tree. Files are read from the object
+// database, so the tree does not need to be checked out. Ignore files are read from the tree, in every directory.
+func SearchTreeForRefs(ctx context.Context, tree *object.Tree, matcher Matcher) ([]gb.ReferenceHunksRep, error) {
+	return searchForRefs(ctx, matcher, func(ctx context.Context, files chan<- file) error {
+		return readTreeFiles(ctx, files, tree)
+	})
+}
+
+// TreeIgnoredPaths returns a function that reports whether a slash-separated path of tree is excluded from
+// scanning, either because it is hidden or because it matches the ignore files of tree.
+func TreeIgnoredPaths(tree *object.Tree) (func(path string) bool, error) {
+	ignores, err := treeIgnoreMatcher(tree)
+	if err != nil {
+		return nil, err
+	}
+	return func(path string) bool {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			// a file is ignored if any of its parent directories is
+			if strings.HasPrefix(segment, ".") || ignores.Match(segments[:i+1], i < len(segments)-1) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// treeIgnoreMatcher returns a matcher of the patterns of all ignore files in tree. Patterns of nested ignore files
+// only apply within their directory, and take precedence over those of their parent directories.
+func treeIgnoreMatcher(tree *object.Tree) (gitignore.Matcher, error) {
+	type ignoreFile struct {
+		domain   []string
+		patterns []gitignore.Pattern
+	}
+	found := []ignoreFile{}
+	err := tree.Files().ForEach(func(f *object.File) error {
+		dir, name := path.Split(f.Name)
+		if !isIgnoreFile(name) || f.Mode != filemode.Regular && f.Mode != filemode.Executable {
+			return nil
+		}
+		var domain []string
+		if dir != "" {
+			domain = strings.Split(strings.TrimSuffix(dir, "/"), "/")
+		}
+		contents, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		ignore := ignoreFile{domain: domain}
+		for _, line := range strings.Split(contents, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+				continue
+			}
+			ignore.patterns = append(ignore.patterns, gitignore.ParsePattern(line, domain))
+		}
+		found = append(found, ignore)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// later patterns take precedence, so those of parent directories go first
+	sort.SliceStable(found, func(i, j int) bool {
+		return len(found[i].domain) < len(found[j].domain)
+	})
+	patterns := []gitignore.Pattern{}
+	for _, ignore := range found {
+		patterns = append(patterns, ignore.patterns...)
+	}
+	return gitignore.NewMatcher(patterns), nil
+}
+
+func isIgnoreFile(name string) bool {
+	for _, ignoreFile := range ignoreFiles {
+		if name == ignoreFile {
+			return true
+		}
+	}
+	return false
+}
+
+func readTreeFiles(ctx context.Context, files chan<- file, tree *object.Tree) error {
+	defer close(files)
+	ignores, err := treeIgnoreMatcher(tree)
+	if err != nil {
+		return err
+	}
+	return walkTree(ctx, files, tree, nil, ignores)
+}
+
+// walkTree sends the text files of tree to files, without descending into hidden or ignored directories.
+// Symbolic links and submodules are skipped.
+func walkTree(ctx context.Context, files chan<- file, tree *object.Tree, dir []string, ignores gitignore.Matcher) error {
+	for i := range tree.Entries {
+		if ctx.Err() != nil {
+			// global context cancelled, don't read any more files
+			return nil
+		}
+		entry := &tree.Entries[i]
+		isDir := entry.Mode == filemode.Dir
+		entryPath := append(append([]string{}, dir...), entry.Name)
+
+		// Skip hidden files and ignored files
+		if strings.HasPrefix(entry.Name, ".") || ignores.Match(entryPath, isDir) {
+			continue
+		}
+
+		switch entry.Mode {
+		case filemode.Dir:
+			subtree, err := tree.Tree(entry.Name)
+			if err != nil {
+				return err
+			}
+			if err := walkTree(ctx, files, subtree, entryPath, ignores); err != nil {
+				return err
+			}
+		case filemode.Regular, filemode.Executable, filemode.Deprecated:
+			f, err := tree.TreeEntryFile(entry)
+			if err != nil {
+				return err
+			}
+			reader, err := f.Reader()
+			if err != nil {
+				return err
+			}
+			contents, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				return err
+			}
+
+			lines := readLines(bytes.NewReader(contents))
+			// only read text files
+			if !util.IsText([]byte(strings.Join(lines, "\n"))) {
+				continue
+			}
+			files <- file{path: strings.Join(entryPath, "/"), lines: lines}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func commitTestTree(t *testing.T, files map[string]string) *object.Tree {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		_, err := wt.Add(name)
+		require.NoError(t, err)
+	}
+	who := object.Signature{Name: "GrowthBook", Email: "dev@growthbook.com", When: time.Unix(100000000, 0)}
+	hash, err := wt.Commit("commit", &git.CommitOptions{Author: &who, Committer: &who})
+	require.NoError(t, err)
+	// files are read from the commit, not the working tree
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "app.js")))
+	commit, err := repo.CommitObject(hash)
+	require.NoError(t, err)
+	tree, err := commit.Tree()
+	require.NoError(t, err)
+	return tree
+}
+
+var testTreeFiles = map[string]string{
+	".gitignore":         "build/\n*.log\n!keep.log\n",
+	".hidden/app.js":     "hidden",
+	"app.js":             "line1\nline2",
+	"binary":             "\x00\x01\x02\x03\x04\x05",
+	"build/out.js":       "built",
+	"debug.log":          "debug",
+	"keep.log":           "kept",
+	"other/generated.js": "other",
+	"sub/.gbignore":      "# generated code\ngenerated.js\n",
+	"sub/generated.js":   "generated",
+	"sub/kept.js":        "kept",
+}
+
+func Test_readTreeFiles(t *testing.T) {
+	tree := commitTestTree(t, testTreeFiles)
+
+	files := make(chan file, 16)
+	require.NoError(t, readTreeFiles(context.Background(), files, tree))
+	got := map[string][]string{}
+	for f := range files {
+		got[f.path] = f.lines
+	}
+	require.Equal(t, map[string][]string{
+		"app.js":             {"line1", "line2"},
+		"keep.log":           {"kept"},
+		"other/generated.js": {"other"},
+		"sub/kept.js":        {"kept"},
+	}, got)
+}
+
+func TestTreeIgnoredPaths(t *testing.T) {
+	tree := commitTestTree(t, testTreeFiles)
+
+	isIgnored, err := TreeIgnoredPaths(tree)
+	require.NoError(t, err)
+	require.False(t, isIgnored("app.js"))
+	require.False(t, isIgnored("keep.log"))
+	require.False(t, isIgnored("other/generated.js"))
+	require.True(t, isIgnored(".hidden/app.js"))
+	require.True(t, isIgnored("build/out.js"))
+	require.True(t, isIgnored("build/nested/out.js"))
+	require.True(t, isIgnored("debug.log"))
+	require.True(t, isIgnored("sub/generated.js"))
+}